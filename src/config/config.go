@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
 
 	"gd-webhook/src/model"
@@ -39,22 +40,47 @@ func GetAppVersion() string {
 
 // Manager manages configuration loading and updates
 type Manager struct {
-	Cfg              *model.Config
-	Lock             sync.RWMutex
-	SARegexRules     []*regexp.Regexp         // Symedia regex rules cache
-	RcloneRegexRules map[int][]*regexp.Regexp // Rclone regex rules cache (Index -> Rules)
+	Cfg               *model.Config
+	Lock              sync.RWMutex
+	SARegexRules      []MappingMatcher            // path_mapping rules cache
+	SymediaRegexRules map[int][]MappingMatcher    // Symedia mapping rules cache (Index -> Rules)
+	RcloneRegexRules  map[int][]MappingMatcher    // Rclone mapping rules cache (Index -> Rules)
+	KodiRegexRules    map[int][]MappingMatcher    // Kodi mapping rules cache (Index -> Rules)
+	AlistRegexRules   map[int][]MappingMatcher    // Alist mapping rules cache (Index -> Rules)
+	CD2RegexRules     map[int][]MappingMatcher    // CloudDrive2 mapping rules cache (Index -> Rules)
+	ExecFilterRules   map[int][]*regexp.Regexp    // Exec path filter cache (Index -> Rules)
+	StrmRegexRules    []MappingMatcher            // STRM mapping rules cache
+	FilterPatterns    map[string][]FilterPattern  // Filter rule patterns cache (Scope -> one entry per rule)
+	MappingSetRules   map[string][]MappingMatcher // Mapping set rules cache (Set name -> Rules)
+	DriveNames        sync.Map                    // Shared drive names cache (Drive ID -> name)
+
+	nameRenderer *NameRenderer // path_render cache (nil = names used as they are)
+}
+
+// FilterPattern holds the compiled path patterns of one filter rule
+type FilterPattern struct {
+	Glob  *regexp.Regexp // nil when the rule has no glob
+	Regex *regexp.Regexp // nil when the rule has no regex
+	Err   error          // Set when a pattern does not compile; the rule never matches
 }
 
 // NewManager creates a new configuration manager
 func NewManager() *Manager {
 	return &Manager{
-		Cfg:              &model.Config{},
-		RcloneRegexRules: make(map[int][]*regexp.Regexp),
+		Cfg:               &model.Config{},
+		SymediaRegexRules: make(map[int][]MappingMatcher),
+		RcloneRegexRules:  make(map[int][]MappingMatcher),
+		KodiRegexRules:    make(map[int][]MappingMatcher),
+		AlistRegexRules:   make(map[int][]MappingMatcher),
+		CD2RegexRules:     make(map[int][]MappingMatcher),
+		ExecFilterRules:   make(map[int][]*regexp.Regexp),
+		FilterPatterns:    make(map[string][]FilterPattern),
+		MappingSetRules:   make(map[string][]MappingMatcher),
 	}
 }
 
-// LoadConfig loads configuration from disk
-func (m *Manager) LoadConfig() {
+// LoadConfig loads configuration from disk; an invalid config is returned as ValidationErrors
+func (m *Manager) LoadConfig() error {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
 
@@ -65,6 +91,11 @@ func (m *Manager) LoadConfig() {
 	// Set default values
 	m.Cfg.Advanced.DebounceSeconds = 5
 	m.Cfg.Advanced.RcloneWaitSeconds = 15
+	m.Cfg.Advanced.VerifyTimeoutSeconds = 120
+	m.Cfg.Advanced.VerifyIntervalSeconds = 2
+	m.Cfg.Advanced.RcloneHealthCheckSeconds = 30
+	m.Cfg.Advanced.StabilityIntervalSeconds = 10
+	m.Cfg.Advanced.StabilityTimeoutSeconds = 600
 	m.Cfg.Advanced.LogSaveEnabled = true
 	m.Cfg.Advanced.LogMaxSizeMB = 10
 	m.Cfg.Advanced.LogRetentionDays = 7
@@ -72,6 +103,8 @@ func (m *Manager) LoadConfig() {
 	m.Cfg.Server.ListenPort = 8448
 	m.Cfg.Server.WebhookPath = "/gd-webhook"
 	m.Cfg.Google.PersonalDriveName = "My Drive"
+	m.Cfg.Google.DriveRootMode = model.DriveRootName
+	m.Cfg.PathRender.Encoding = defaultEncoding
 
 	// Track if we need to save config (for first-time setup)
 	needSave := false
@@ -85,10 +118,7 @@ func (m *Manager) LoadConfig() {
 		needSave = true
 	} else {
 		if err := json.Unmarshal(f, m.Cfg); err != nil {
-			// Try old format compatibility (if rclone is object instead of array)
-			// For simplicity, assume user has updated config format or using new config
-			fmt.Printf("❌ Config file JSON format error (ensure rclone config is an array): %v\n", err)
-			return
+			return fmt.Errorf("JSON format error (ensure rclone config is an array): %w", err)
 		}
 	}
 
@@ -133,11 +163,35 @@ func (m *Manager) LoadConfig() {
 		m.Cfg.Server.WebhookPath = "/gd-webhook"
 	}
 
-	// Set defaults for Symedia timeout (Default 60s, Max 120s)
-	if m.Cfg.Symedia.Timeout <= 0 {
-		m.Cfg.Symedia.Timeout = 60
-	} else if m.Cfg.Symedia.Timeout > 120 {
-		m.Cfg.Symedia.Timeout = 120
+	// Set defaults for visibility checks (Timeout 120s, Interval 2s)
+	if m.Cfg.Advanced.VerifyTimeoutSeconds <= 0 {
+		m.Cfg.Advanced.VerifyTimeoutSeconds = 120
+	}
+	if m.Cfg.Advanced.VerifyIntervalSeconds <= 0 {
+		m.Cfg.Advanced.VerifyIntervalSeconds = 2
+	}
+	if m.Cfg.Advanced.RcloneHealthCheckSeconds <= 0 {
+		m.Cfg.Advanced.RcloneHealthCheckSeconds = 30
+	}
+
+	// Set defaults for upload stability checks (Interval 10s, Timeout 600s)
+	if m.Cfg.Advanced.StabilityIntervalSeconds <= 0 {
+		m.Cfg.Advanced.StabilityIntervalSeconds = 10
+	}
+	if m.Cfg.Advanced.StabilityTimeoutSeconds <= 0 {
+		m.Cfg.Advanced.StabilityTimeoutSeconds = 600
+	}
+
+	// Set defaults for Symedia names and timeouts (Default 60s, Max 120s)
+	for i := range m.Cfg.Symedia {
+		if m.Cfg.Symedia[i].Name == "" {
+			m.Cfg.Symedia[i].Name = defaultSymediaName(i)
+		}
+		if m.Cfg.Symedia[i].Timeout <= 0 {
+			m.Cfg.Symedia[i].Timeout = 60
+		} else if m.Cfg.Symedia[i].Timeout > 120 {
+			m.Cfg.Symedia[i].Timeout = 120
+		}
 	}
 
 	// Set defaults for Google ListDelay (Min 1000ms)
@@ -153,9 +207,15 @@ func (m *Manager) LoadConfig() {
 	if m.Cfg.Google.TargetDriveRemarks == nil {
 		m.Cfg.Google.TargetDriveRemarks = make(map[string]string)
 	}
+	if m.Cfg.Google.DriveRoots == nil {
+		m.Cfg.Google.DriveRoots = make(map[string]model.DriveRoot)
+	}
 
-	// Set defaults for Rclone timeouts (Default 60s, Max 120s)
+	// Set defaults for Rclone names and timeouts (Default 60s, Max 120s)
 	for i := range m.Cfg.Rclone {
+		if m.Cfg.Rclone[i].Name == "" {
+			m.Cfg.Rclone[i].Name = defaultRcloneName(i)
+		}
 		if m.Cfg.Rclone[i].Timeout <= 0 {
 			m.Cfg.Rclone[i].Timeout = 60
 		} else if m.Cfg.Rclone[i].Timeout > 120 {
@@ -163,28 +223,54 @@ func (m *Manager) LoadConfig() {
 		}
 	}
 
-	// Compile regex rules
-	m.SARegexRules = nil
-	for _, mapping := range m.Cfg.Mapping {
-		r, err := regexp.Compile(mapping.Regex)
-		if err == nil {
-			m.SARegexRules = append(m.SARegexRules, r)
+	// Set defaults for Kodi timeouts (Default 30s, Max 120s) and clean debounce (Default 60s)
+	for i := range m.Cfg.Kodi {
+		if m.Cfg.Kodi[i].Timeout <= 0 {
+			m.Cfg.Kodi[i].Timeout = 30
+		} else if m.Cfg.Kodi[i].Timeout > 120 {
+			m.Cfg.Kodi[i].Timeout = 120
+		}
+		if m.Cfg.Kodi[i].CleanDebounceSeconds <= 0 {
+			m.Cfg.Kodi[i].CleanDebounceSeconds = 60
 		}
 	}
 
-	m.RcloneRegexRules = make(map[int][]*regexp.Regexp)
-	for idx, instance := range m.Cfg.Rclone {
-		var rules []*regexp.Regexp
-		for _, mapping := range instance.Mapping {
-			r, err := regexp.Compile(mapping.Regex)
-			if err == nil {
-				rules = append(rules, r)
-			}
+	// Set defaults for Alist / CloudDrive2 timeouts (Default 60s, Max 120s)
+	for i := range m.Cfg.Alist {
+		if m.Cfg.Alist[i].Timeout <= 0 {
+			m.Cfg.Alist[i].Timeout = 60
+		} else if m.Cfg.Alist[i].Timeout > 120 {
+			m.Cfg.Alist[i].Timeout = 120
+		}
+	}
+	for i := range m.Cfg.CloudDrive2 {
+		if m.Cfg.CloudDrive2[i].Timeout <= 0 {
+			m.Cfg.CloudDrive2[i].Timeout = 60
+		} else if m.Cfg.CloudDrive2[i].Timeout > 120 {
+			m.Cfg.CloudDrive2[i].Timeout = 120
 		}
-		m.RcloneRegexRules[idx] = rules
 	}
 
-	fmt.Printf("📜 Loaded %d SA rules, %d Rclone instances\n", len(m.SARegexRules), len(m.Cfg.Rclone))
+	// Set defaults for Exec timeouts (Default 300s) and concurrency (Default 1)
+	for i := range m.Cfg.Exec {
+		if m.Cfg.Exec[i].Timeout <= 0 {
+			m.Cfg.Exec[i].Timeout = 300
+		}
+		if m.Cfg.Exec[i].Concurrency <= 0 {
+			m.Cfg.Exec[i].Concurrency = 1
+		}
+	}
+
+	if errs := Validate(m.Cfg); len(errs) > 0 {
+		return errs
+	}
+
+	// Compile regex rules
+	m.compileRegexRules()
+
+	fmt.Printf("📜 Loaded %d Symedia instances, %d SA rules, %d Rclone instances, %d Alist instances, %d CloudDrive2 instances, %d Kodi hosts\n",
+		len(m.Cfg.Symedia), len(m.SARegexRules), len(m.Cfg.Rclone), len(m.Cfg.Alist), len(m.Cfg.CloudDrive2), len(m.Cfg.Kodi))
+	return nil
 }
 
 // saveConfigWithoutLock saves configuration without acquiring lock (for internal use)
@@ -215,15 +301,24 @@ func (m *Manager) GetConfig() model.Config {
 	return *m.Cfg
 }
 
-// UpdateConfig updates configuration and recompiles regex rules
-func (m *Manager) UpdateConfig(newCfg model.Config) {
+// UpdateConfig validates and applies a configuration and recompiles regex rules;
+// an invalid configuration is rejected as ValidationErrors and nothing is applied
+func (m *Manager) UpdateConfig(newCfg model.Config) error {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
 	// Validate/Fix configuration
-	if newCfg.Symedia.Timeout > 120 {
-		newCfg.Symedia.Timeout = 120
+	for i := range newCfg.Symedia {
+		if newCfg.Symedia[i].Name == "" {
+			newCfg.Symedia[i].Name = defaultSymediaName(i)
+		}
+		if newCfg.Symedia[i].Timeout > 120 {
+			newCfg.Symedia[i].Timeout = 120
+		}
 	}
 	for i := range newCfg.Rclone {
+		if newCfg.Rclone[i].Name == "" {
+			newCfg.Rclone[i].Name = defaultRcloneName(i)
+		}
 		if newCfg.Rclone[i].Timeout > 120 {
 			newCfg.Rclone[i].Timeout = 120
 		}
@@ -231,29 +326,231 @@ func (m *Manager) UpdateConfig(newCfg model.Config) {
 	if newCfg.Google.ListDelay < 1000 {
 		newCfg.Google.ListDelay = 1000
 	}
+	if newCfg.Google.DriveRootMode == "" {
+		newCfg.Google.DriveRootMode = model.DriveRootName
+	}
+	if newCfg.PathRender.Encoding == "" {
+		newCfg.PathRender.Encoding = defaultEncoding
+	}
+	if newCfg.Advanced.VerifyTimeoutSeconds <= 0 {
+		newCfg.Advanced.VerifyTimeoutSeconds = 120
+	}
+	if newCfg.Advanced.VerifyIntervalSeconds <= 0 {
+		newCfg.Advanced.VerifyIntervalSeconds = 2
+	}
+	if newCfg.Advanced.RcloneHealthCheckSeconds <= 0 {
+		newCfg.Advanced.RcloneHealthCheckSeconds = 30
+	}
+	if newCfg.Advanced.StabilityIntervalSeconds <= 0 {
+		newCfg.Advanced.StabilityIntervalSeconds = 10
+	}
+	if newCfg.Advanced.StabilityTimeoutSeconds <= 0 {
+		newCfg.Advanced.StabilityTimeoutSeconds = 600
+	}
+
+	for i := range newCfg.Kodi {
+		if newCfg.Kodi[i].Timeout > 120 {
+			newCfg.Kodi[i].Timeout = 120
+		}
+		if newCfg.Kodi[i].CleanDebounceSeconds <= 0 {
+			newCfg.Kodi[i].CleanDebounceSeconds = 60
+		}
+	}
+
+	for i := range newCfg.Alist {
+		if newCfg.Alist[i].Timeout > 120 {
+			newCfg.Alist[i].Timeout = 120
+		}
+	}
+	for i := range newCfg.CloudDrive2 {
+		if newCfg.CloudDrive2[i].Timeout > 120 {
+			newCfg.CloudDrive2[i].Timeout = 120
+		}
+	}
+	for i := range newCfg.Exec {
+		if newCfg.Exec[i].Timeout <= 0 {
+			newCfg.Exec[i].Timeout = 300
+		}
+		if newCfg.Exec[i].Concurrency <= 0 {
+			newCfg.Exec[i].Concurrency = 1
+		}
+	}
+
+	if errs := Validate(&newCfg); len(errs) > 0 {
+		return errs
+	}
 
 	*m.Cfg = newCfg
 
 	// Recompile regex rules
-	m.SARegexRules = nil
-	for _, mapping := range m.Cfg.Mapping {
-		r, err := regexp.Compile(mapping.Regex)
-		if err == nil {
-			m.SARegexRules = append(m.SARegexRules, r)
-		}
+	m.compileRegexRules()
+	return nil
+}
+
+// compileRegexRules rebuilds all regex rule caches from current config (caller must hold lock)
+func (m *Manager) compileRegexRules() {
+	m.SARegexRules = m.compileMappingRules(m.Cfg.Mapping)
+
+	m.SymediaRegexRules = make(map[int][]MappingMatcher)
+	for idx, instance := range m.Cfg.Symedia {
+		m.SymediaRegexRules[idx] = m.compileMappingRules(instance.Mapping)
 	}
 
-	m.RcloneRegexRules = make(map[int][]*regexp.Regexp)
+	m.RcloneRegexRules = make(map[int][]MappingMatcher)
 	for idx, instance := range m.Cfg.Rclone {
-		var rules []*regexp.Regexp
-		for _, mapping := range instance.Mapping {
-			r, err := regexp.Compile(mapping.Regex)
-			if err == nil {
-				rules = append(rules, r)
+		m.RcloneRegexRules[idx] = m.compileMappingRules(instance.Mapping)
+	}
+
+	m.KodiRegexRules = make(map[int][]MappingMatcher)
+	for idx, instance := range m.Cfg.Kodi {
+		m.KodiRegexRules[idx] = m.compileMappingRules(instance.Mapping)
+	}
+
+	m.AlistRegexRules = make(map[int][]MappingMatcher)
+	for idx, instance := range m.Cfg.Alist {
+		m.AlistRegexRules[idx] = m.compileMappingRules(instance.Mapping)
+	}
+
+	m.CD2RegexRules = make(map[int][]MappingMatcher)
+	for idx, instance := range m.Cfg.CloudDrive2 {
+		m.CD2RegexRules[idx] = m.compileMappingRules(instance.Mapping)
+	}
+
+	m.StrmRegexRules = m.compileMappingRules(m.Cfg.Strm.Mapping)
+
+	m.MappingSetRules = make(map[string][]MappingMatcher)
+	for name, mapping := range m.Cfg.MappingSets {
+		m.MappingSetRules[name] = m.compileMappingRules(mapping)
+	}
+
+	m.nameRenderer = compileNameRenderer(m.Cfg.PathRender)
+
+	m.ExecFilterRules = make(map[int][]*regexp.Regexp)
+	for idx, sink := range m.Cfg.Exec {
+		// Invalid filters stay as nil entries so the list keeps its indexes and never matches everything
+		rules := make([]*regexp.Regexp, len(sink.PathFilters))
+		for j, f := range sink.PathFilters {
+			rules[j], _ = regexp.Compile(f)
+		}
+		m.ExecFilterRules[idx] = rules
+	}
+
+	m.FilterPatterns = make(map[string][]FilterPattern)
+	m.FilterPatterns[FilterScopeGlobal] = compileFilterRules(m.Cfg.Filters.Rules)
+	m.FilterPatterns[FilterScopeStrm] = compileFilterRules(m.Cfg.Strm.Filters.Rules)
+	for idx, instance := range m.Cfg.Symedia {
+		m.FilterPatterns[SymediaFilterScope(idx)] = compileFilterRules(instance.Filters.Rules)
+	}
+	for idx, sink := range m.Cfg.Webhooks {
+		m.FilterPatterns[WebhookFilterScope(idx)] = compileFilterRules(sink.Filters.Rules)
+	}
+	for idx, sink := range m.Cfg.Exec {
+		m.FilterPatterns[ExecFilterScope(idx)] = compileFilterRules(sink.Filters.Rules)
+	}
+}
+
+// Filter scopes used as FilterPatterns keys
+const (
+	FilterScopeGlobal = "global"
+	FilterScopeStrm   = "strm"
+)
+
+// SymediaFilterScope returns the FilterPatterns key of a Symedia instance
+func SymediaFilterScope(idx int) string { return fmt.Sprintf("symedia:%d", idx) }
+
+// WebhookFilterScope returns the FilterPatterns key of a webhook sink
+func WebhookFilterScope(idx int) string { return fmt.Sprintf("webhook:%d", idx) }
+
+// ExecFilterScope returns the FilterPatterns key of an exec sink
+func ExecFilterScope(idx int) string { return fmt.Sprintf("exec:%d", idx) }
+
+// defaultSymediaName names a Symedia instance configured without a name
+func defaultSymediaName(idx int) string {
+	if idx == 0 {
+		return "default"
+	}
+	return fmt.Sprintf("symedia-%d", idx+1)
+}
+
+// defaultRcloneName names an Rclone instance configured without a name; health state and queues are keyed by name
+func defaultRcloneName(idx int) string {
+	return fmt.Sprintf("rclone-%d", idx+1)
+}
+
+// compileFilterRules compiles the glob and regex of each filter rule, keeping rule indexes aligned
+func compileFilterRules(rules []model.FilterRule) []FilterPattern {
+	patterns := make([]FilterPattern, len(rules))
+	for i, rule := range rules {
+		if rule.Glob != "" {
+			patterns[i].Glob, patterns[i].Err = regexp.Compile(GlobToRegex(rule.Glob))
+		}
+		if rule.Regex != "" && patterns[i].Err == nil {
+			patterns[i].Regex, patterns[i].Err = regexp.Compile(rule.Regex)
+		}
+	}
+	return patterns
+}
+
+// GlobToRegex converts a path glob to an anchored regex: "**" matches across directories,
+// "*" and "?" stay within one path segment, [...] is a character class and {a,b} an alternative
+func GlobToRegex(glob string) string {
+	return globToRegex(glob, false)
+}
+
+// globToRegex converts a path glob to an anchored regex; with capture, every wildcard is a group
+func globToRegex(glob string, capture bool) string {
+	group := func(expr string) string {
+		if capture {
+			return "(" + expr + ")"
+		}
+		return expr
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '{':
+			braces++
+			b.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			b.WriteString(")")
+		case c == ',' && braces > 0:
+			b.WriteString("|")
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			i++
+			// "**/" also matches no directory at all
+			if i+1 < len(glob) && glob[i+1] == '/' {
+				i++
+				b.WriteString(group("(?:.*/)?"))
+			} else {
+				b.WriteString(group(".*"))
+			}
+		case c == '*':
+			b.WriteString(group("[^/]*"))
+		case c == '?':
+			b.WriteString(group("[^/]"))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
 			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
 		}
-		m.RcloneRegexRules[idx] = rules
 	}
+	b.WriteString("$")
+	return b.String()
 }
 
 // SaveCredentialsFile regenerates credentials.json
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"path"
+
+	"gd-webhook/src/model"
+)
+
+// DriveRootKey returns the key of a drive in drive_roots and remarks: its ID, or "root" for My Drive
+func DriveRootKey(driveID string) string {
+	if driveID == "" {
+		return "root"
+	}
+	return driveID
+}
+
+// FixedDriveRoot returns the configured root of a drive's paths, or "" when they are rooted at its name
+func (m *Manager) FixedDriveRoot(driveID string) string {
+	m.Lock.RLock()
+	defer m.Lock.RUnlock()
+	return fixedDriveRoot(m.Cfg, driveID)
+}
+
+// fixedDriveRoot resolves the root of a drive from google.drive_root_mode and google.drive_roots
+func fixedDriveRoot(cfg *model.Config, driveID string) string {
+	key := DriveRootKey(driveID)
+	root := cfg.Google.DriveRoots[key]
+	mode := root.Mode
+	if mode == "" {
+		mode = cfg.Google.DriveRootMode
+	}
+
+	switch mode {
+	case model.DriveRootCustom:
+		if root.Prefix != "" {
+			return path.Clean("/" + root.Prefix)
+		}
+	case model.DriveRootID:
+		return "/" + key
+	case model.DriveRootRemark:
+		if remark := cfg.Google.TargetDriveRemarks[key]; remark != "" {
+			return "/" + remark
+		}
+	}
+	return ""
+}
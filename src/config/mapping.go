@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gd-webhook/src/model"
+)
+
+// MappingMatcher is a compiled path mapping rule
+type MappingMatcher struct {
+	Type        string         // regex | prefix | glob | drive
+	Pattern     *regexp.Regexp // Compiled regex or glob (nil for prefix and drive rules)
+	Prefix      string         // Leading path without trailing slash ("" matches every path)
+	DriveID     string         // Drive whose root is replaced (drive rules)
+	Replacement string
+	Continue    bool
+	Err         error // Set when the rule does not compile; the rule never matches
+
+	fixedRoot  string    // Configured root of the drive when the rule was compiled ("" = its name)
+	personal   string    // Root name of My Drive when the rule was compiled
+	driveNames *sync.Map // Shared drive names cache (Drive ID -> name)
+}
+
+// Map applies the rule to originPath, returning the mapped path and whether the rule matched
+func (r MappingMatcher) Map(originPath string) (string, bool) {
+	if r.Err != nil {
+		return originPath, false
+	}
+	switch r.Type {
+	case model.MappingPrefix:
+		return replacePrefix(originPath, r.Prefix, r.Replacement)
+	case model.MappingDrive:
+		root, ok := r.driveRoot()
+		if !ok {
+			return originPath, false
+		}
+		return replacePrefix(originPath, root, r.Replacement)
+	default:
+		if r.Pattern == nil || !r.Pattern.MatchString(originPath) {
+			return originPath, false
+		}
+		return r.Pattern.ReplaceAllString(originPath, r.Replacement), true
+	}
+}
+
+// String describes the rule pattern, e.g. "prefix:/Media"
+func (r MappingMatcher) String() string {
+	switch r.Type {
+	case model.MappingPrefix:
+		return r.Type + ":" + r.Prefix + "/"
+	case model.MappingDrive:
+		return r.Type + ":" + r.DriveID
+	default:
+		if r.Pattern == nil {
+			return r.Type + ":"
+		}
+		return r.Type + ":" + r.Pattern.String()
+	}
+}
+
+// driveRoot returns the root path of the rule's drive; shared drives rooted at their name are only
+// known once the name is resolved
+func (r MappingMatcher) driveRoot() (string, bool) {
+	if r.fixedRoot != "" {
+		return r.fixedRoot, true
+	}
+	if r.DriveID == "" || r.DriveID == "root" {
+		return "/" + r.personal, true
+	}
+	if r.driveNames == nil {
+		return "", false
+	}
+	name, ok := r.driveNames.Load(r.DriveID)
+	if !ok {
+		return "", false
+	}
+	return "/" + name.(string), true
+}
+
+// replacePrefix replaces prefix in originPath when it ends at a path segment boundary
+func replacePrefix(originPath, prefix, replacement string) (string, bool) {
+	if prefix != "" && originPath != prefix && !strings.HasPrefix(originPath, prefix+"/") {
+		return originPath, false
+	}
+	finalPath := strings.TrimRight(replacement, "/") + originPath[len(prefix):]
+	if finalPath == "" {
+		finalPath = "/"
+	}
+	return finalPath, true
+}
+
+// PersonalDriveName returns the root name of My Drive in rendered paths
+func PersonalDriveName(cfg *model.Config) string {
+	if cfg.Google.PersonalDriveName == "" {
+		return "Cloud Drive"
+	}
+	return cfg.Google.PersonalDriveName
+}
+
+// compileMappingRule compiles one mapping rule
+func compileMappingRule(rule model.MappingRule) MappingMatcher {
+	matcher := MappingMatcher{Type: rule.Type, Replacement: rule.Replacement, Continue: rule.Continue}
+	switch rule.Type {
+	case "", model.MappingRegex:
+		matcher.Type = model.MappingRegex
+		matcher.Pattern, matcher.Err = regexp.Compile(rule.Regex)
+	case model.MappingPrefix:
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			matcher.Err = fmt.Errorf("prefix must start with /")
+		}
+		matcher.Prefix = strings.TrimRight(rule.Prefix, "/")
+	case model.MappingGlob:
+		matcher.Pattern, matcher.Err = regexp.Compile(globToRegex(rule.Glob, true))
+	case model.MappingDrive:
+		if rule.DriveID == "" {
+			matcher.Err = fmt.Errorf("drive_id is required")
+		}
+		matcher.DriveID = rule.DriveID
+	default:
+		matcher.Err = fmt.Errorf("unknown type %q", rule.Type)
+	}
+	return matcher
+}
+
+// compileMappingRules compiles a mapping list (caller must hold lock); invalid rules keep their
+// index with Err set so rule indexes keep matching the mapping list
+func (m *Manager) compileMappingRules(mappings []model.MappingRule) []MappingMatcher {
+	personal := PersonalDriveName(m.Cfg)
+	rules := make([]MappingMatcher, len(mappings))
+	for i, mapping := range mappings {
+		rules[i] = compileMappingRule(mapping)
+		rules[i].personal = personal
+		if mapping.Type == model.MappingDrive {
+			rules[i].fixedRoot = fixedDriveRoot(m.Cfg, mapping.DriveID)
+		}
+		rules[i].driveNames = &m.DriveNames
+	}
+	return rules
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"gd-webhook/src/model"
+)
+
+// Encoding flags, named and applied like rclone's lib/encoder
+const (
+	encodeZero uint32 = 1 << iota
+	encodeSlash
+	encodeLtGt
+	encodeSquareBracket
+	encodeSemicolon
+	encodeExclamation
+	encodeDoubleQuote
+	encodeSingleQuote
+	encodeBackQuote
+	encodeDollar
+	encodeColon
+	encodeQuestion
+	encodeAsterisk
+	encodePipe
+	encodeHash
+	encodePercent
+	encodeBackSlash
+	encodeCrLf
+	encodeDel
+	encodeCtl
+	encodeLeftSpace
+	encodeLeftPeriod
+	encodeLeftTilde
+	encodeLeftCrLfHtVt
+	encodeRightSpace
+	encodeRightPeriod
+	encodeRightCrLfHtVt
+	encodeInvalidUtf8
+	encodeDot
+)
+
+// encodingFlags maps rclone encoding names to flags
+var encodingFlags = map[string]uint32{
+	"none":          0,
+	"zero":          encodeZero,
+	"slash":         encodeSlash,
+	"ltgt":          encodeLtGt,
+	"squarebracket": encodeSquareBracket,
+	"semicolon":     encodeSemicolon,
+	"exclamation":   encodeExclamation,
+	"doublequote":   encodeDoubleQuote,
+	"singlequote":   encodeSingleQuote,
+	"backquote":     encodeBackQuote,
+	"dollar":        encodeDollar,
+	"colon":         encodeColon,
+	"question":      encodeQuestion,
+	"asterisk":      encodeAsterisk,
+	"pipe":          encodePipe,
+	"hash":          encodeHash,
+	"percent":       encodePercent,
+	"backslash":     encodeBackSlash,
+	"crlf":          encodeCrLf,
+	"del":           encodeDel,
+	"ctl":           encodeCtl,
+	"leftspace":     encodeLeftSpace,
+	"leftperiod":    encodeLeftPeriod,
+	"lefttilde":     encodeLeftTilde,
+	"leftcrlfhtvt":  encodeLeftCrLfHtVt,
+	"rightspace":    encodeRightSpace,
+	"rightperiod":   encodeRightPeriod,
+	"rightcrlfhtvt": encodeRightCrLfHtVt,
+	"invalidutf8":   encodeInvalidUtf8,
+	"dot":           encodeDot,
+}
+
+// fullWidthFlags are the characters replaced by their full-width form (c + 0xFEE0)
+var fullWidthFlags = map[rune]uint32{
+	'/': encodeSlash, '<': encodeLtGt, '>': encodeLtGt, '[': encodeSquareBracket, ']': encodeSquareBracket,
+	';': encodeSemicolon, '!': encodeExclamation, '"': encodeDoubleQuote, '\'': encodeSingleQuote,
+	'`': encodeBackQuote, '$': encodeDollar, ':': encodeColon, '?': encodeQuestion, '*': encodeAsterisk,
+	'|': encodePipe, '#': encodeHash, '%': encodePercent, '\\': encodeBackSlash,
+}
+
+// defaultEncoding is the encoding of rclone's drive backend
+const defaultEncoding = "InvalidUtf8"
+
+const (
+	quoteRune       = '‛'    // Marks a character that is already in its encoded form, or quotes an invalid byte (‛FE)
+	symbolOffset    = 0x2400 // Control characters become control pictures (␀ ␁ ...)
+	fullWidthOffset = 0xFEE0 // ASCII punctuation becomes its full-width form (／ ： ...)
+)
+
+// NameRenderer renders Drive file names into path segments the way rclone exposes them
+type NameRenderer struct {
+	flags     uint32
+	normalize string // NFC | NFD | "" (none)
+}
+
+// ParseEncoding parses comma separated rclone encoding names, e.g. "Slash,LeftSpace,InvalidUtf8"
+func ParseEncoding(encoding string) (uint32, error) {
+	var flags uint32
+	for _, name := range strings.Split(encoding, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		flag, ok := encodingFlags[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown encoding %q", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// NameRenderer returns the current name renderer, nil when names are used as they are
+func (m *Manager) NameRenderer() *NameRenderer {
+	m.Lock.RLock()
+	defer m.Lock.RUnlock()
+	return m.nameRenderer
+}
+
+// compileNameRenderer builds the name renderer of a path_render config
+func compileNameRenderer(cfg model.PathRenderConfig) *NameRenderer {
+	if !cfg.RcloneEncoding && cfg.Normalize == "" {
+		return nil
+	}
+	r := &NameRenderer{normalize: cfg.Normalize}
+	if cfg.RcloneEncoding {
+		// A name can't keep "/" inside a path, so it is encoded whatever the flags
+		flags, _ := ParseEncoding(cfg.Encoding)
+		r.flags = flags | encodeSlash
+	}
+	return r
+}
+
+// Render normalizes and encodes one file name; a nil renderer returns the name unchanged
+func (r *NameRenderer) Render(name string) string {
+	if r == nil {
+		return name
+	}
+	switch r.normalize {
+	case model.NormalizeNFC:
+		name = norm.NFC.String(name)
+	case model.NormalizeNFD:
+		name = norm.NFD.String(name)
+	}
+	if r.flags == 0 {
+		return name
+	}
+	return r.encode(name)
+}
+
+// encode applies the encoding flags to a name
+func (r *NameRenderer) encode(name string) string {
+	has := func(flag uint32) bool { return r.flags&flag != 0 }
+
+	if has(encodeDot) && (name == "." || name == "..") {
+		return strings.Repeat(string(rune('.'+fullWidthOffset)), len(name))
+	}
+
+	var b strings.Builder
+	last := len(name) - 1
+	for i := 0; i < len(name); {
+		c, size := utf8.DecodeRuneInString(name[i:])
+		first, final := i == 0, i+size-1 == last
+		if c == utf8.RuneError && size == 1 {
+			if has(encodeInvalidUtf8) {
+				fmt.Fprintf(&b, "%c%02X", quoteRune, name[i])
+			} else {
+				b.WriteByte(name[i])
+			}
+			i += size
+			continue
+		}
+		i += size
+
+		if encoded, ok := r.encodeRune(c, first, final); ok {
+			b.WriteRune(encoded)
+			continue
+		}
+		if r.isEncodedForm(c, first, final) {
+			// Keep a character that looks encoded apart from an encoded one
+			b.WriteRune(quoteRune)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// encodeRune returns the encoded form of a character at a position of the name
+func (r *NameRenderer) encodeRune(c rune, first, final bool) (rune, bool) {
+	has := func(flag uint32) bool { return r.flags&flag != 0 }
+
+	switch {
+	case first && c == ' ' && has(encodeLeftSpace), final && c == ' ' && has(encodeRightSpace):
+		return symbolOffset + c, true
+	case first && c == '.' && has(encodeLeftPeriod), final && c == '.' && has(encodeRightPeriod):
+		return c + fullWidthOffset, true
+	case first && c == '~' && has(encodeLeftTilde):
+		return c + fullWidthOffset, true
+	case isCrLfHtVt(c) && (first && has(encodeLeftCrLfHtVt) || final && has(encodeRightCrLfHtVt)):
+		return symbolOffset + c, true
+	case c == 0 && has(encodeZero):
+		return symbolOffset, true
+	case (c == '\r' || c == '\n') && (has(encodeCrLf) || has(encodeCtl)):
+		return symbolOffset + c, true
+	case c > 0 && c < 0x20 && has(encodeCtl):
+		return symbolOffset + c, true
+	case c == 0x7F && has(encodeDel):
+		return '␡', true
+	}
+	if flag, ok := fullWidthFlags[c]; ok && has(flag) {
+		return c + fullWidthOffset, true
+	}
+	return c, false
+}
+
+// isEncodedForm reports whether a character equals what an active flag encodes to
+func (r *NameRenderer) isEncodedForm(c rune, first, final bool) bool {
+	switch {
+	case c == quoteRune:
+		return true
+	case c == '␡':
+		return r.flags&encodeDel != 0
+	case c >= symbolOffset && c <= symbolOffset+' ':
+		_, ok := r.encodeRune(c-symbolOffset, first, final)
+		return ok
+	case c > fullWidthOffset && c < fullWidthOffset+0x7F:
+		_, ok := r.encodeRune(c-fullWidthOffset, first, final)
+		return ok
+	}
+	return false
+}
+
+// isCrLfHtVt reports whether c is a carriage return, line feed, horizontal or vertical tab
+func isCrLfHtVt(c rune) bool {
+	return c == '\r' || c == '\n' || c == '\t' || c == '\v'
+}
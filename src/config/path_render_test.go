@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"gd-webhook/src/model"
+)
+
+// Expected names as rclone's lib/encoder renders them (see the encoding tables of rclone's docs)
+func TestRenderName(t *testing.T) {
+	tests := []struct {
+		encoding  string
+		normalize string
+		in        string
+		want      string
+	}{
+		{"InvalidUtf8", "", "plain.mkv", "plain.mkv"},
+		{"InvalidUtf8", "", "a\xFEb", "a‛FEb"},
+		{"InvalidUtf8", "", "\xBF\xC0", "‛BF‛C0"},
+		{"InvalidUtf8", "", "a/b", "a／b"},
+		{"InvalidUtf8", "", "a／b", "a‛／b"},
+		{"None", "", "a\xFEb", "a\xFEb"},
+		{"LeftSpace,RightSpace", "", " a b ", "␠a b␠"},
+		{"LeftPeriod,RightPeriod", "", ".a.b.", "．a.b．"},
+		{"LeftTilde", "", "~a~", "～a~"},
+		{"LeftCrLfHtVt,RightCrLfHtVt", "", "\tab\n", "␉ab␊"},
+		{"Ctl", "", "a\x01b", "a␁b"},
+		{"Zero,Del", "", "a\x00b\x7F", "a␀b␡"},
+		{"Colon,Question,Asterisk,Pipe", "", "a:b?c*d|e", "a：b？c＊d｜e"},
+		{"LtGt,DoubleQuote,BackSlash", "", `<a"b\c>`, `＜a＂b＼c＞`},
+		{"Colon", "", "a：b", "a‛：b"},
+		{"Dot", "", "..", "．．"},
+		{"", model.NormalizeNFC, "e\u0301", "\u00e9"},
+		{"", model.NormalizeNFD, "\u00e9", "e\u0301"},
+	}
+	for _, tt := range tests {
+		r := compileNameRenderer(model.PathRenderConfig{RcloneEncoding: tt.encoding != "", Encoding: tt.encoding, Normalize: tt.normalize})
+		if got := r.Render(tt.in); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.encoding, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+
+	"gd-webhook/src/model"
+)
+
+// ValidationErrors lists the invalid fields of a config
+type ValidationErrors []model.FieldError
+
+// Error joins the field errors into one message
+func (e ValidationErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid config: " + strings.Join(parts, "; ")
+}
+
+// extraValidators are checks registered by packages config cannot import (e.g. route expressions)
+var extraValidators []func(cfg *model.Config) []model.FieldError
+
+// RegisterValidator adds a check run by Validate
+func RegisterValidator(fn func(cfg *model.Config) []model.FieldError) {
+	extraValidators = append(extraValidators, fn)
+}
+
+// cronParser parses cron expressions the way the scheduler does (with seconds)
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validator collects field errors
+type validator struct {
+	errs []model.FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, model.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks a config and returns every invalid field (nil if valid)
+func Validate(cfg *model.Config) ValidationErrors {
+	v := &validator{}
+
+	// Server
+	if cfg.Server.ListenPort < 1 || cfg.Server.ListenPort > 65535 {
+		v.add("server.listen_port", "must be between 1 and 65535")
+	}
+	if cfg.Server.PublicURL != "" {
+		v.url("server.public_url", cfg.Server.PublicURL, false)
+	}
+	if cfg.Server.WebhookPath != "" && !strings.HasPrefix(cfg.Server.WebhookPath, "/") {
+		v.add("server.webhook_path", "must start with /")
+	}
+	if cfg.Server.SSL.Enabled {
+		v.file("server.ssl.cert_path", cfg.Server.SSL.CertPath)
+		v.file("server.ssl.key_path", cfg.Server.SSL.KeyPath)
+	}
+
+	// Advanced
+	if cfg.Advanced.LogCleanupCron != "" {
+		if _, err := cronParser.Parse(cfg.Advanced.LogCleanupCron); err != nil {
+			v.add("advanced.log_cleanup_cron", "%v", err)
+		}
+	}
+
+	// Google
+	seenDrives := make(map[string]int)
+	for i, id := range cfg.Google.TargetDriveIDs {
+		if first, ok := seenDrives[id]; ok {
+			v.add(fmt.Sprintf("google.target_drive_ids[%d]", i), "duplicate of target_drive_ids[%d]", first)
+		} else {
+			seenDrives[id] = i
+		}
+	}
+	v.oneOf("google.drive_root_mode", cfg.Google.DriveRootMode, model.DriveRootName, model.DriveRootRemark, model.DriveRootID)
+	rootKeys := make([]string, 0, len(cfg.Google.DriveRoots))
+	for key := range cfg.Google.DriveRoots {
+		rootKeys = append(rootKeys, key)
+	}
+	sort.Strings(rootKeys)
+	roots := make(map[string]string) // Fixed root -> drive key
+	for _, key := range rootKeys {
+		field := "google.drive_roots." + key
+		root := cfg.Google.DriveRoots[key]
+		v.oneOf(field+".mode", root.Mode, model.DriveRootName, model.DriveRootRemark, model.DriveRootID, model.DriveRootCustom)
+		if root.Mode == model.DriveRootCustom && !strings.HasPrefix(root.Prefix, "/") {
+			v.add(field+".prefix", "must start with / in custom mode")
+			continue
+		}
+		if fixed := fixedDriveRoot(cfg, key); fixed != "" {
+			if other, ok := roots[fixed]; ok {
+				v.add(field, "root %s is also used by drive %s", fixed, other)
+			}
+			roots[fixed] = key
+		}
+	}
+
+	// Path rendering
+	if _, err := ParseEncoding(cfg.PathRender.Encoding); err != nil {
+		v.add("path_render.encoding", "%v", err)
+	}
+	v.oneOf("path_render.normalize", cfg.PathRender.Normalize, model.NormalizeNFC, model.NormalizeNFD)
+
+	// Targets
+	v.mapping("path_mapping", cfg.Mapping)
+	names := newNameSet(v, "symedia")
+	for i, inst := range cfg.Symedia {
+		field := fmt.Sprintf("symedia[%d]", i)
+		names.check(field, inst.Name)
+		if !strings.Contains(inst.Host, "{{") {
+			v.url(field+".host", inst.Host, false)
+		}
+		v.subtreePolicy(field+".subtree_policy", inst.SubtreePolicy)
+		v.filters(field+".filters", inst.Filters)
+		v.mapping(field+".mapping", inst.Mapping)
+	}
+	names = newNameSet(v, "rclone")
+	for i, inst := range cfg.Rclone {
+		field := fmt.Sprintf("rclone[%d]", i)
+		names.check(field, inst.Name)
+		v.url(field+".host", inst.Host, true)
+		if inst.FanInThreshold < 0 || inst.FanInThreshold == 1 {
+			v.add(field+".fan_in_threshold", "must be 0 (disabled) or at least 2")
+		}
+		if inst.CACert != "" {
+			v.file(field+".ca_cert", inst.CACert)
+		}
+		v.mapping(field+".mapping", inst.Mapping)
+	}
+	names = newNameSet(v, "kodi")
+	for i, inst := range cfg.Kodi {
+		field := fmt.Sprintf("kodi[%d]", i)
+		names.check(field, inst.Name)
+		v.url(field+".host", inst.Host, false)
+		v.mapping(field+".mapping", inst.Mapping)
+	}
+	names = newNameSet(v, "alist")
+	for i, inst := range cfg.Alist {
+		field := fmt.Sprintf("alist[%d]", i)
+		names.check(field, inst.Name)
+		v.url(field+".host", inst.Host, false)
+		v.mapping(field+".mapping", inst.Mapping)
+	}
+	names = newNameSet(v, "clouddrive2")
+	for i, inst := range cfg.CloudDrive2 {
+		field := fmt.Sprintf("clouddrive2[%d]", i)
+		names.check(field, inst.Name)
+		v.url(field+".host", inst.Host, false)
+		v.mapping(field+".mapping", inst.Mapping)
+	}
+	names = newNameSet(v, "webhooks")
+	for i, sink := range cfg.Webhooks {
+		field := fmt.Sprintf("webhooks[%d]", i)
+		names.check(field, sink.Name)
+		v.url(field+".url", sink.URL, false)
+		v.subtreePolicy(field+".subtree_policy", sink.SubtreePolicy)
+		v.filters(field+".filters", sink.Filters)
+	}
+	names = newNameSet(v, "exec")
+	for i, sink := range cfg.Exec {
+		field := fmt.Sprintf("exec[%d]", i)
+		names.check(field, sink.Name)
+		for j, f := range sink.PathFilters {
+			if _, err := regexp.Compile(f); err != nil {
+				v.add(fmt.Sprintf("%s.path_filters[%d]", field, j), "invalid regex: %v", err)
+			}
+		}
+		v.subtreePolicy(field+".subtree_policy", sink.SubtreePolicy)
+		v.filters(field+".filters", sink.Filters)
+	}
+	v.mapping("strm.mapping", cfg.Strm.Mapping)
+	v.filters("strm.filters", cfg.Strm.Filters)
+	names = newNameSet(v, "crypt")
+	for i, rule := range cfg.Crypt {
+		field := fmt.Sprintf("crypt[%d]", i)
+		names.check(field, rule.Name)
+		v.oneOf(field+".filename_encryption", rule.FilenameEncryption, "standard", "obfuscate", "off")
+		v.oneOf(field+".filename_encoding", rule.FilenameEncoding, "base32", "base64")
+	}
+	v.filters("filters", cfg.Filters)
+	for name, mapping := range cfg.MappingSets {
+		v.mapping(fmt.Sprintf("mapping_sets.%s", name), mapping)
+	}
+
+	for _, fn := range extraValidators {
+		v.errs = append(v.errs, fn(cfg)...)
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// url checks an http(s) URL (or a unix socket URL when allowed)
+func (v *validator) url(field, raw string, allowUnix bool) {
+	if raw == "" {
+		v.add(field, "is required")
+		return
+	}
+	if allowUnix && strings.HasPrefix(raw, "unix://") {
+		if strings.TrimPrefix(raw, "unix://") == "" {
+			v.add(field, "socket path is missing")
+		}
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		v.add(field, "invalid URL: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		v.add(field, "must start with http:// or https://")
+	} else if u.Host == "" {
+		v.add(field, "host is missing")
+	}
+}
+
+// file checks that a path names an existing file
+func (v *validator) file(field, path string) {
+	if path == "" {
+		v.add(field, "is required")
+		return
+	}
+	if info, err := os.Stat(path); err != nil {
+		v.add(field, "file not found: %s", path)
+	} else if info.IsDir() {
+		v.add(field, "is a directory: %s", path)
+	}
+}
+
+// mapping checks the type and pattern of each rule in a list of mapping rules
+func (v *validator) mapping(field string, rules []model.MappingRule) {
+	for i, rule := range rules {
+		ruleField := fmt.Sprintf("%s[%d]", field, i)
+		switch rule.Type {
+		case "", model.MappingRegex:
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				v.add(ruleField+".regex", "invalid regex: %v", err)
+			}
+		case model.MappingPrefix:
+			if !strings.HasPrefix(rule.Prefix, "/") {
+				v.add(ruleField+".prefix", "must start with /")
+			}
+		case model.MappingGlob:
+			if _, err := regexp.Compile(globToRegex(rule.Glob, true)); err != nil {
+				v.add(ruleField+".glob", "invalid glob: %v", err)
+			}
+		case model.MappingDrive:
+			if rule.DriveID == "" {
+				v.add(ruleField+".drive_id", "is required for drive rules")
+			}
+		default:
+			v.oneOf(ruleField+".type", rule.Type, model.MappingRegex, model.MappingPrefix, model.MappingGlob, model.MappingDrive)
+		}
+	}
+}
+
+// filters checks the decisions and patterns of a filter
+func (v *validator) filters(field string, filter model.FilterConfig) {
+	v.oneOf(field+".default", filter.Default, model.FilterInclude, model.FilterExclude)
+	for i, rule := range filter.Rules {
+		ruleField := fmt.Sprintf("%s.rules[%d]", field, i)
+		v.oneOf(ruleField+".action", rule.Action, model.FilterInclude, model.FilterExclude)
+		if rule.Glob != "" {
+			if _, err := regexp.Compile(GlobToRegex(rule.Glob)); err != nil {
+				v.add(ruleField+".glob", "invalid glob: %v", err)
+			}
+		}
+		if rule.Regex != "" {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				v.add(ruleField+".regex", "invalid regex: %v", err)
+			}
+		}
+		if rule.MinSize < 0 || rule.MaxSize < 0 {
+			v.add(ruleField, "min_size and max_size must not be negative")
+		} else if rule.MaxSize > 0 && rule.MaxSize < rule.MinSize {
+			v.add(ruleField+".max_size", "is smaller than min_size")
+		}
+	}
+}
+
+// subtreePolicy checks a subtree policy name
+func (v *validator) subtreePolicy(field, policy string) {
+	v.oneOf(field, policy, model.SubtreeAll, model.SubtreeTopLevel, model.SubtreeFiles)
+}
+
+// oneOf checks that an optional value is one of the allowed values
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// nameSet reports duplicate names within one list of targets
+type nameSet struct {
+	v     *validator
+	list  string
+	first map[string]int
+	count int
+}
+
+func newNameSet(v *validator, list string) *nameSet {
+	return &nameSet{v: v, list: list, first: make(map[string]int)}
+}
+
+// check records the name of the next list entry; empty names are not compared
+func (n *nameSet) check(field, name string) {
+	idx := n.count
+	n.count++
+	if name == "" {
+		return
+	}
+	if first, ok := n.first[name]; ok {
+		n.v.add(field+".name", "duplicate of %s[%d]", n.list, first)
+		return
+	}
+	n.first[name] = idx
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -13,7 +16,18 @@ import (
 
 func main() {
 	cfgManager := config.NewManager()
-	cfgManager.LoadConfig()
+	if err := cfgManager.LoadConfig(); err != nil {
+		var errs config.ValidationErrors
+		if errors.As(err, &errs) {
+			fmt.Println("❌ Config file is invalid, fix these fields and restart:")
+			for _, fe := range errs {
+				fmt.Printf("   - %s: %s\n", fe.Field, fe.Message)
+			}
+		} else {
+			fmt.Printf("❌ Failed to load config file: %v\n", err)
+		}
+		os.Exit(1)
+	}
 
 	cfg := cfgManager.GetConfig()
 	logger.InitLogging(&cfg)
@@ -25,8 +39,15 @@ func main() {
 	driveService := service.NewDriveService(cfgManager)
 	fileTree := service.NewFileTree(driveService)
 	rcloneService := service.NewRcloneService(cfgManager)
+	alistService := service.NewAlistService(cfgManager)
+	cd2Service := service.NewCloudDrive2Service(cfgManager)
 	symediaService := service.NewSymediaService(cfgManager)
-	syncService := service.NewSyncService(cfgManager, driveService, fileTree, rcloneService, symediaService)
+	kodiService := service.NewKodiService(cfgManager)
+	webhookService := service.NewWebhookService(cfgManager)
+	execService := service.NewExecService(cfgManager)
+	cryptService := service.NewCryptService(cfgManager)
+	strmService := service.NewStrmService(cfgManager, fileTree, cryptService)
+	syncService := service.NewSyncService(cfgManager, driveService, fileTree, rcloneService, alistService, cd2Service, symediaService, kodiService, webhookService, execService, strmService, cryptService)
 
 	cronRunner := cron.New(cron.WithSeconds())
 	cronRunner.Start()
@@ -45,7 +66,7 @@ func main() {
 	}
 
 	middleware := server.NewMiddleware(cfgManager)
-	handler := server.NewHandler(cfgManager, driveService, syncService, rcloneService, symediaService)
+	handler := server.NewHandler(cfgManager, driveService, syncService, rcloneService, symediaService, strmService)
 	srv := server.NewServer(cfgManager, handler, middleware)
 
 	go func() {
@@ -102,6 +123,7 @@ func main() {
 	}()
 
 	go syncService.StartProcessLoop()
+	go rcloneService.StartHealthLoop()
 
 	select {}
 }
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 const (
 	DataDir        = "userdata/data"
 	ConfigDir      = "userdata/config"
@@ -11,6 +16,24 @@ const (
 
 	// MaxWebLogs is the max log lines displayed in frontend
 	MaxWebLogs = 500
+
+	// EventSchemaVersion is the version of the outgoing ChangeEvent JSON schema.
+	// Bump only on breaking changes; adding optional fields is not breaking.
+	EventSchemaVersion = 1
+)
+
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+	ActionMove   = "move"
+	ActionModify = "modify"
+)
+
+// Subtree policies decide which events of a moved or deleted directory tree a sink receives
+const (
+	SubtreeAll      = "all"       // The directory and every descendant
+	SubtreeTopLevel = "top_level" // Only the top-level directory
+	SubtreeFiles    = "files"     // Only files (descendants expanded, directories dropped)
 )
 
 const (
@@ -40,6 +63,24 @@ type Config struct {
 		DebounceSeconds   int    `json:"debounce_seconds"`
 		RcloneWaitSeconds int    `json:"rclone_wait_seconds"`
 
+		// Visibility check before notifying (replaces the fixed Rclone cooldown when enabled)
+		VerifyVisibility      bool `json:"verify_visibility"`
+		VerifyTimeoutSeconds  int  `json:"verify_timeout_seconds"`  // Give up and notify anyway after this long
+		VerifyIntervalSeconds int  `json:"verify_interval_seconds"` // Poll interval for job/status and VFS lookups
+
+		RcloneHealthCheckSeconds int `json:"rclone_health_check_seconds"` // Interval of rclone instance health probes
+
+		// Hold delete notifications; a file ID reappearing within the window is reported as a move (0 = disabled)
+		DeleteGraceSeconds int `json:"delete_grace_seconds"`
+
+		// Buffer events this long and fold them per file ID before refreshing and notifying (0 = per sync run only)
+		EventCompactionSeconds int `json:"event_compaction_seconds"`
+
+		// Hold create/modify events until Drive reports the same size and md5 this many checks in a row (0 = disabled)
+		StabilityChecks          int `json:"stability_checks"`
+		StabilityIntervalSeconds int `json:"stability_interval_seconds"` // Time between checks
+		StabilityTimeoutSeconds  int `json:"stability_timeout_seconds"`  // Release the event anyway after this long
+
 		// Log cleanup config
 		LogCleanupEnabled bool   `json:"log_cleanup_enabled"` // Enable/disable
 		LogRetentionDays  int    `json:"log_retention_days"`  // Retention days
@@ -66,41 +107,255 @@ type Config struct {
 	} `json:"server"`
 
 	Google struct {
-		RateLimitQPS       int               `json:"rate_limit_qps"`
-		PersonalDriveName  string            `json:"personal_drive_name"`
-		TargetDriveIDs     []string          `json:"target_drive_ids"`     // Target Team Drive IDs
-		TargetDriveRemarks map[string]string `json:"target_drive_remarks"` // Remarks for target drives
-		ListDelay          int               `json:"list_delay"`           // Milliseconds, min 1000
-		BatchSleepInterval int               `json:"batch_sleep_interval"` // Sleep seconds every 1000 items
+		RateLimitQPS       int                  `json:"rate_limit_qps"`
+		PersonalDriveName  string               `json:"personal_drive_name"`
+		TargetDriveIDs     []string             `json:"target_drive_ids"`     // Target Team Drive IDs
+		TargetDriveRemarks map[string]string    `json:"target_drive_remarks"` // Remarks for target drives
+		DriveRootMode      string               `json:"drive_root_mode"`      // Root of drive paths: name (default) | remark | id
+		DriveRoots         map[string]DriveRoot `json:"drive_roots"`          // Per-drive root settings (Drive ID, "root" = My Drive)
+		ListDelay          int                  `json:"list_delay"`           // Milliseconds, min 1000
+		BatchSleepInterval int                  `json:"batch_sleep_interval"` // Sleep seconds every 1000 items
 	} `json:"google"`
 
-	Rclone  []RcloneInstance `json:"rclone"`
-	Symedia struct {
-		Host            string                 `json:"host"`
-		Endpoint        string                 `json:"endpoint"`
-		NotifyUnmatched bool                   `json:"notify_unmatched"`
-		Headers         map[string]string      `json:"headers"`
-		BodyTemplate    map[string]interface{} `json:"body_template"`
-		Timeout         int                    `json:"timeout"` // Seconds
-	} `json:"symedia"`
-	Mapping []MappingRule `json:"path_mapping"`
+	Rclone      []RcloneInstance      `json:"rclone"`
+	Symedia     SymediaInstances      `json:"symedia"`
+	Mapping     []MappingRule         `json:"path_mapping"`
+	Kodi        []KodiInstance        `json:"kodi"`
+	Alist       []AlistInstance       `json:"alist"`
+	CloudDrive2 []CloudDrive2Instance `json:"clouddrive2"`
+	Webhooks    []WebhookSink         `json:"webhooks"`
+	Exec        []ExecSink            `json:"exec"`
+	Strm        StrmConfig            `json:"strm"`
+	Crypt       []CryptRule           `json:"crypt"`
+	Filters     FilterConfig          `json:"filters"` // Applied to every change before caches and sinks
+	PathRender  PathRenderConfig      `json:"path_render"`
+
+	Routes      []RouteRule              `json:"routes"`
+	MappingSets map[string][]MappingRule `json:"mapping_sets"` // Named path mappings selectable by routes
+}
+
+// RouteRule selects the sinks and the Symedia mapping set of the events its expression matches.
+// Routes are evaluated in order and the first match decides; unmatched events go to every sink.
+type RouteRule struct {
+	Name       string   `json:"name"`
+	When       string   `json:"when"`        // Expression, e.g. action == "delete" && ext in ["mkv", "mp4"]
+	Sinks      []string `json:"sinks"`       // symedia | strm | kodi | webhook:<name> | exec:<name> (empty = none)
+	MappingSet string   `json:"mapping_set"` // Key of mapping_sets used instead of the Symedia instance mapping (optional)
+}
+
+// SymediaInstance represents a Symedia (or compatible) endpoint notified about changes
+type SymediaInstance struct {
+	Name            string                 `json:"name"`
+	Host            string                 `json:"host"`
+	Endpoint        string                 `json:"endpoint"`
+	NotifyUnmatched bool                   `json:"notify_unmatched"`
+	Headers         map[string]string      `json:"headers"`
+	BodyTemplate    map[string]interface{} `json:"body_template"`
+	Query           map[string]string      `json:"query"`          // Templated query parameters (nil = copy static top-level body values)
+	Timeout         int                    `json:"timeout"`        // Seconds
+	SubtreePolicy   string                 `json:"subtree_policy"` // all | top_level | files
+	Filters         FilterConfig           `json:"filters"`
+	Mapping         []MappingRule          `json:"mapping"` // Empty = path_mapping
+}
+
+// SymediaInstances is the list of Symedia instances; a single object (the former format) is read as one instance
+type SymediaInstances []SymediaInstance
+
+// UnmarshalJSON accepts both an array of instances and a single instance object
+func (l *SymediaInstances) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var inst SymediaInstance
+		if err := json.Unmarshal(trimmed, &inst); err != nil {
+			return err
+		}
+		// An object without a host was never set up
+		*l = SymediaInstances{}
+		if inst.Host != "" {
+			*l = SymediaInstances{inst}
+		}
+		return nil
+	}
+	var list []SymediaInstance
+	if err := json.Unmarshal(trimmed, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
 }
 
 // RcloneInstance represents Rclone instance configuration
 type RcloneInstance struct {
+	Name               string            `json:"name"`
+	Host               string            `json:"host"` // http(s)://host:port or unix:///path/to/rc.sock
+	Endpoint           string            `json:"endpoint"`
+	Group              string            `json:"group"`                // Failover group: refreshes go to the first healthy member only
+	Fs                 string            `json:"fs"`                   // Remote of the VFS for vfs/* calls, e.g. "gdrive:" (empty = the only VFS)
+	Timeout            int               `json:"timeout"`              // Seconds
+	FanInThreshold     int               `json:"fan_in_threshold"`     // Refresh a parent recursively once this many subdirectories need a refresh (0 = disabled)
+	CooldownSeconds    int               `json:"cooldown_seconds"`     // Wait before notifying about paths handled here (0 = advanced.rclone_wait_seconds)
+	Username           string            `json:"username"`             // --rc-user
+	Password           string            `json:"password"`             // --rc-pass
+	Headers            map[string]string `json:"headers"`              // Extra request headers
+	CACert             string            `json:"ca_cert"`              // PEM file trusted for HTTPS (in addition to system roots)
+	InsecureSkipVerify bool              `json:"insecure_skip_verify"` // Skip TLS certificate verification
+	Mapping            []MappingRule     `json:"mapping"`
+}
+
+// AlistInstance represents Alist directory cache refresh configuration
+type AlistInstance struct {
 	Name     string        `json:"name"`
-	Host     string        `json:"host"`
-	Endpoint string        `json:"endpoint"`
-	Timeout  int           `json:"timeout"` // Seconds
+	Host     string        `json:"host"`     // e.g. http://127.0.0.1:5244
+	Token    string        `json:"token"`    // Admin token (Settings -> Other -> Token)
+	Password string        `json:"password"` // Folder password (optional)
+	Timeout  int           `json:"timeout"`  // Seconds
 	Mapping  []MappingRule `json:"mapping"`
 }
 
-// MappingRule represents path mapping rule
+// CloudDrive2Instance represents CloudDrive2 directory cache refresh configuration
+type CloudDrive2Instance struct {
+	Name    string        `json:"name"`
+	Host    string        `json:"host"`    // e.g. http://127.0.0.1:19798
+	Token   string        `json:"token"`   // API token
+	Timeout int           `json:"timeout"` // Seconds
+	Mapping []MappingRule `json:"mapping"`
+}
+
+// WebhookSink represents a generic outgoing webhook receiving ChangeEvents
+type WebhookSink struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	Secret    string            `json:"secret"`     // HMAC-SHA256 signing key (empty to disable signing)
+	Batch     bool              `json:"batch"`      // Send all events of a sync run in one request
+	BatchSize int               `json:"batch_size"` // Max events per batch request (0 = unlimited)
+	Timeout   int               `json:"timeout"`    // Seconds
+
+	SubtreePolicy string       `json:"subtree_policy"` // all | top_level | files
+	Filters       FilterConfig `json:"filters"`
+}
+
+// ExecSink represents a local command run on change events
+type ExecSink struct {
+	Name        string            `json:"name"`
+	Command     string            `json:"command"`      // Shell command line (run via sh -c)
+	WorkDir     string            `json:"work_dir"`     // Working directory (optional)
+	Env         map[string]string `json:"env"`          // Extra environment variables
+	Batch       bool              `json:"batch"`        // Run once per sync run instead of once per event
+	Timeout     int               `json:"timeout"`      // Seconds
+	Concurrency int               `json:"concurrency"`  // Max parallel runs (per-event mode)
+	PathFilters []string          `json:"path_filters"` // Regexes; event runs if path or old path matches any (empty = all)
+
+	SubtreePolicy string       `json:"subtree_policy"` // all | top_level | files
+	Filters       FilterConfig `json:"filters"`
+}
+
+// StrmConfig represents .strm file generation configuration
+type StrmConfig struct {
+	Enabled     bool          `json:"enabled"`
+	OutputDir   string        `json:"output_dir"`   // Local directory receiving the .strm tree
+	URLTemplate string        `json:"url_template"` // e.g. http://alist:5244/d{{FILE_PATH_URLENCODED}}
+	Extensions  []string      `json:"extensions"`   // Media extensions without dot (empty = defaults)
+	Mapping     []MappingRule `json:"mapping"`      // Rewrite source path before writing (optional)
+	Filters     FilterConfig  `json:"filters"`
+}
+
+// FilterConfig is an ordered list of include/exclude rules; the first matching rule decides
+type FilterConfig struct {
+	Default string       `json:"default"` // Decision when no rule matches: include (default) | exclude
+	Rules   []FilterRule `json:"rules"`
+}
+
+// FilterRule matches changes on every condition that is set
+type FilterRule struct {
+	Name       string   `json:"name"`       // Shown in dry-run results (optional)
+	Action     string   `json:"action"`     // include | exclude
+	Glob       string   `json:"glob"`       // Path glob (*, **, ?, [...]); without "/" it matches the file name
+	Regex      string   `json:"regex"`      // Path regex
+	Extensions []string `json:"extensions"` // Case-insensitive, with or without dot
+	MimeTypes  []string `json:"mime_types"` // Exact types, or prefixes ending in "*"
+	MinSize    int64    `json:"min_size"`   // Bytes (0 = no lower bound)
+	MaxSize    int64    `json:"max_size"`   // Bytes (0 = no upper bound)
+	Drives     []string `json:"drives"`     // Drive IDs or names ("root" = My Drive)
+	IsDir      *bool    `json:"is_dir"`     // Only directories (true) or only files (false)
+}
+
+const (
+	FilterInclude = "include"
+	FilterExclude = "exclude"
+)
+
+// CryptRule describes an rclone crypt remote stored inside a target drive or folder
+type CryptRule struct {
+	Name                    string `json:"name"`
+	DriveID                 string `json:"drive_id"`                  // Target drive ID (empty = any drive)
+	PathPrefix              string `json:"path_prefix"`               // Path of the crypt remote root as rendered by the tree, e.g. /Media/crypt
+	Password                string `json:"password"`                  // rclone crypt password
+	Salt                    string `json:"salt"`                      // rclone crypt password2 (empty = rclone default salt)
+	Obscured                bool   `json:"obscured"`                  // Password and salt are "rclone obscure"d (as in rclone.conf)
+	FilenameEncryption      string `json:"filename_encryption"`       // standard | obfuscate | off
+	FilenameEncoding        string `json:"filename_encoding"`         // base32 | base64
+	DirectoryNameEncryption *bool  `json:"directory_name_encryption"` // Default true
+}
+
+// KodiInstance represents Kodi JSON-RPC host configuration
+type KodiInstance struct {
+	Name                 string        `json:"name"`
+	Host                 string        `json:"host"` // e.g. http://192.168.1.10:8080
+	Username             string        `json:"username"`
+	Password             string        `json:"password"`
+	Timeout              int           `json:"timeout"`                // Seconds
+	CleanDebounceSeconds int           `json:"clean_debounce_seconds"` // Delay before VideoLibrary.Clean after deletes
+	Mapping              []MappingRule `json:"mapping"`
+}
+
+// PathRenderConfig controls how Drive file names become path segments
+type PathRenderConfig struct {
+	RcloneEncoding bool   `json:"rclone_encoding"` // Encode names as rclone's drive backend exposes them
+	Encoding       string `json:"encoding"`        // rclone encoding names, comma separated (default InvalidUtf8, the drive backend default)
+	Normalize      string `json:"normalize"`       // Unicode normalization of names: NFC | NFD (empty = none)
+}
+
+// Unicode normalization forms
+const (
+	NormalizeNFC = "NFC"
+	NormalizeNFD = "NFD"
+)
+
+// DriveRoot sets the first path segment(s) of one drive's paths
+type DriveRoot struct {
+	Mode   string `json:"mode"`   // name | remark | id | custom (empty = drive_root_mode)
+	Prefix string `json:"prefix"` // Root path of custom mode, e.g. /Media/Movies
+}
+
+// Drive root modes
+const (
+	DriveRootName   = "name"   // Drive name, e.g. /My Drive
+	DriveRootRemark = "remark" // Remark of the target drive, the name when it has none
+	DriveRootID     = "id"     // Drive ID, e.g. /0AbCdEf (My Drive = /root)
+	DriveRootCustom = "custom" // Fixed prefix
+)
+
+// MappingRule represents path mapping rule. Rules are tried in order and the first match stops,
+// unless it sets continue: then later rules are tried too and every match adds a target path.
 type MappingRule struct {
-	Regex       string `json:"regex"`       // Search pattern
+	Type        string `json:"type"`        // regex (default) | prefix | glob | drive
+	Regex       string `json:"regex"`       // Search pattern (regex)
+	Prefix      string `json:"prefix"`      // Leading path replaced at a segment boundary (prefix)
+	Glob        string `json:"glob"`        // Whole-path glob, wildcards captured as $1, $2... (glob)
+	DriveID     string `json:"drive_id"`    // Drive whose root is replaced, "root" = My Drive (drive)
 	Replacement string `json:"replacement"` // Replacement text (empty to delete)
+	Continue    bool   `json:"continue"`    // Keep matching later rules after this one
 }
 
+// Mapping rule types
+const (
+	MappingRegex  = "regex"
+	MappingPrefix = "prefix"
+	MappingGlob   = "glob"
+	MappingDrive  = "drive"
+)
+
 // GoogleCredJSON represents Google credentials JSON structure
 type GoogleCredJSON struct {
 	Web struct {
@@ -123,6 +378,45 @@ type FileNode struct {
 	DriveID  string
 }
 
+// ChangeEvent is a single file change produced by a sync run (documented in docs/API.md)
+type ChangeEvent struct {
+	Action       string `json:"action"`             // create | delete | move | modify
+	Path         string `json:"path"`               // Current path (last known path for deletes)
+	OldPath      string `json:"old_path,omitempty"` // Previous path (moves only)
+	FileID       string `json:"file_id"`
+	DriveID      string `json:"drive_id"`
+	DriveName    string `json:"drive_name"`
+	IsDir        bool   `json:"is_dir"`
+	MimeType     string `json:"mime_type,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	CreatedTime  string `json:"created_time,omitempty"`  // RFC3339, as reported by Drive
+	ModifiedTime string `json:"modified_time,omitempty"` // RFC3339, as reported by Drive
+	EventTime    string `json:"event_time"`              // RFC3339, time of the change
+	SyncRunID    string `json:"sync_run_id"`
+
+	// Descendants lists the contents of a moved or deleted directory. It is only evaluated
+	// when a sink's subtree policy needs the child events (nil for files and empty dirs).
+	Descendants func() []SubtreeItem `json:"-"`
+}
+
+// SubtreeItem is a descendant of a directory event, relative to the directory
+type SubtreeItem struct {
+	Rel     string // Path below the directory, starting with "/"
+	OldRel  string // Path below the old directory for moves (empty if the same as Rel)
+	FileID  string
+	DriveID string
+	IsDir   bool
+}
+
+// EventEnvelope wraps ChangeEvents sent to generic webhooks
+type EventEnvelope struct {
+	SchemaVersion int           `json:"schema_version"`
+	DeliveryID    string        `json:"delivery_id"`
+	SentAt        string        `json:"sent_at"` // RFC3339
+	Source        string        `json:"source"`
+	Events        []ChangeEvent `json:"events"`
+}
+
 // DescendantInfo contains traversal result information
 type DescendantInfo struct {
 	ID      string
@@ -142,7 +436,148 @@ type OAuthLoginURLResponse struct {
 	URL string `json:"url"`
 }
 
+// RcloneTestResponse represents the rclone connection test result
+type RcloneTestResponse struct {
+	OK        bool   `json:"ok"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// FilterTestRequest describes a change to run through the filters without processing it
+type FilterTestRequest struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path"`
+	IsDir     bool   `json:"is_dir"`
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mime_type"`
+	DriveID   string `json:"drive_id"`
+	DriveName string `json:"drive_name"`
+}
+
+// FilterDecision explains the outcome of one filter scope
+type FilterDecision struct {
+	Scope    string `json:"scope"` // global | symedia | strm | webhook:<name> | exec:<name>
+	Accepted bool   `json:"accepted"`
+	Rule     int    `json:"rule"` // Index of the deciding rule (-1 = default)
+	RuleName string `json:"rule_name,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// RouteTestRequest evaluates routes (the saved ones unless given) or a single expression against sample events
+type RouteTestRequest struct {
+	Expression string        `json:"expression"`
+	Routes     []RouteRule   `json:"routes"`
+	Events     []ChangeEvent `json:"events"`
+}
+
+// RouteTestResult is the outcome of one sample event
+type RouteTestResult struct {
+	Path       string   `json:"path"`
+	Matched    bool     `json:"matched"`
+	Route      int      `json:"route"` // Index of the deciding route (-1 = none)
+	RouteName  string   `json:"route_name,omitempty"`
+	Sinks      []string `json:"sinks"`
+	MappingSet string   `json:"mapping_set,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// TemplateVars are the variables available in payload templates
+type TemplateVars struct {
+	Action       string `json:"action"`
+	FilePath     string `json:"file_path"`   // Mapped path
+	OriginPath   string `json:"origin_path"` // Path before mapping
+	FileName     string `json:"file_name"`
+	Ext          string `json:"ext"`        // Lower case, without dot
+	ParentDir    string `json:"parent_dir"` // Parent of the mapped path
+	OldPath      string `json:"old_path"`   // Previous path of moves (unmapped)
+	IsDir        bool   `json:"is_dir"`
+	DriveID      string `json:"drive_id"` // "My Drive" for the personal drive
+	DriveName    string `json:"drive_name"`
+	FileID       string `json:"file_id"`
+	Size         int64  `json:"size"`
+	MimeType     string `json:"mime_type"`
+	CreatedTime  string `json:"created_time"`
+	ModifiedTime string `json:"modified_time"`
+	EventTime    string `json:"event_time"`
+	Timestamp    int64  `json:"timestamp"` // Unix seconds when the request is built
+}
+
+// TemplatePreviewRequest renders the Symedia requests of a sample event without sending them
+type TemplatePreviewRequest struct {
+	Event      ChangeEvent `json:"event"`
+	Instance   string      `json:"instance"` // Symedia instance name (empty = all)
+	MappingSet string      `json:"mapping_set"`
+}
+
+// TemplatePreview is one rendered Symedia request
+type TemplatePreview struct {
+	Instance string            `json:"instance"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Body     interface{}       `json:"body"`
+	Vars     TemplateVars      `json:"vars"`
+	Matched  bool              `json:"matched"` // A path mapping rule matched (unmatched paths are skipped unless notify_unmatched)
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+// MappingTestRequest asks where a change would be sent, without contacting any service
+type MappingTestRequest struct {
+	Path   string `json:"path"`    // Drive path, e.g. "/My Drive/Movies/Movie.mkv"
+	FileID string `json:"file_id"` // Resolved through the file tree when path is empty
+	Action string `json:"action"`  // create (default) | delete | modify
+	IsDir  bool   `json:"is_dir"`  // Taken from the file tree when file_id is set
+}
+
+// MappingTestResponse lists how Symedia and every Rclone instance map a path
+type MappingTestResponse struct {
+	Path    string              `json:"path"` // Source path (resolved from file_id if needed)
+	Symedia []MappingTestResult `json:"symedia"`
+	Rclone  []MappingTestResult `json:"rclone"`
+}
+
+// MappingTestResult describes how one instance maps the source path and what it would be sent
+type MappingTestResult struct {
+	Instance  string              `json:"instance"`
+	Source    string              `json:"source,omitempty"`  // Rules used: mapping | path_mapping | mapping_set:<name>
+	Rule      int                 `json:"rule"`              // Index of the first matching rule (-1 = none)
+	Pattern   string              `json:"pattern,omitempty"` // Type and pattern of that rule, e.g. prefix:/Media/
+	SmartRoot bool                `json:"smart_root"`        // Matched only with a trailing slash
+	Path      string              `json:"path"`              // First mapped path
+	Targets   []MappingTarget     `json:"targets,omitempty"` // Every mapped path (several when rules continue)
+	Skipped   string              `json:"skipped,omitempty"`
+	Requests  []TemplatePreview   `json:"requests,omitempty"` // Symedia requests
+	Calls     []RcloneCallPreview `json:"calls,omitempty"`    // Rclone rc requests
+}
+
+// MappingTarget is one path a mapping rule produced
+type MappingTarget struct {
+	Rule    int    `json:"rule"`
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+}
+
+// RcloneCallPreview is one rc request an Rclone instance would receive
+type RcloneCallPreview struct {
+	Endpoint string                 `json:"endpoint"`
+	Async    bool                   `json:"async"`
+	Payload  map[string]interface{} `json:"payload"`
+}
+
+// FieldError is one invalid config field
+type FieldError struct {
+	Field   string `json:"field"` // JSON path, e.g. "rclone[1].mapping[0].regex"
+	Message string `json:"message"`
+}
+
+// ConfigErrorResponse is returned when a config update is rejected
+type ConfigErrorResponse struct {
+	Error  string       `json:"error"`
+	Errors []FieldError `json:"errors"`
+}
+
 // TestSymediaRequest represents test webhook request body
 type TestSymediaRequest struct {
-	Path string `json:"path"`
+	Path     string `json:"path"`
+	Instance string `json:"instance"` // Symedia instance name (empty = all)
 }
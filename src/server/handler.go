@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -35,6 +36,7 @@ type Handler struct {
 	Sync          *service.SyncService
 	Rclone        *service.RcloneService
 	Symedia       *service.SymediaService
+	Strm          *service.StrmService
 	Middleware    *Middleware
 	TotalMemory   uint64
 }
@@ -46,6 +48,7 @@ func NewHandler(
 	ss *service.SyncService,
 	rc *service.RcloneService,
 	sy *service.SymediaService,
+	st *service.StrmService,
 ) *Handler {
 	return &Handler{
 		ConfigManager: cm,
@@ -53,6 +56,7 @@ func NewHandler(
 		Sync:          ss,
 		Rclone:        rc,
 		Symedia:       sy,
+		Strm:          st,
 		TotalMemory:   getTotalMemory(),
 	}
 }
@@ -306,8 +310,12 @@ func (h *Handler) HandleSystemStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Get task statistics
 	var taskStats service.TaskStats
+	var compactionStats service.CompactionStats
+	stabilityPending := 0
 	if h.Sync != nil {
 		taskStats = h.Sync.GetTaskStats()
+		compactionStats = h.Sync.GetCompactionStats()
+		stabilityPending = h.Sync.StabilityPending()
 	}
 
 	// Get memory statistics
@@ -348,6 +356,9 @@ func (h *Handler) HandleSystemStatus(w http.ResponseWriter, r *http.Request) {
 		"memory_alloc_mb":         float64(memStats.Alloc) / 1024 / 1024,
 		"memory_sys_mb":           float64(memStats.Sys) / 1024 / 1024,
 		"goroutines":              numGoroutines,
+		"rclone":                  h.Rclone.HealthStatus(),
+		"compaction":              compactionStats,
+		"stability_pending":       stabilityPending,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -464,12 +475,40 @@ func (h *Handler) HandleConfigUpdate(w http.ResponseWriter, r *http.Request) {
 
 	logger.Debug(oldCfg.Advanced.LogLevel, "[Debug] Config Update - New Remarks (Final): %v", newCfg.Google.TargetDriveRemarks)
 
+	if newCfg.Google.DriveRoots == nil {
+		newCfg.Google.DriveRoots = oldCfg.Google.DriveRoots
+	}
+	if newCfg.Google.DriveRootMode == "" {
+		newCfg.Google.DriveRootMode = oldCfg.Google.DriveRootMode
+	}
+
+	// Preserve backend-only sections the dashboard doesn't send
+	var rawSections map[string]json.RawMessage
+	_ = json.Unmarshal(bodyBytes, &rawSections)
+	preserveOmittedSections(rawSections, &newCfg, &oldCfg)
+
 	logChanged := (newCfg.Advanced.LogDir != oldCfg.Advanced.LogDir) ||
 		(newCfg.Advanced.LogSaveEnabled != oldCfg.Advanced.LogSaveEnabled)
 
 	addrChanged := (newCfg.Server.PublicURL != oldCfg.Server.PublicURL) ||
 		(newCfg.Server.WebhookPath != oldCfg.Server.WebhookPath)
 
+	// Invalid configs are rejected as a whole, with one error per field
+	if err := h.ConfigManager.UpdateConfig(newCfg); err != nil {
+		resp := model.ConfigErrorResponse{Error: err.Error()}
+		var errs config.ValidationErrors
+		if errors.As(err, &errs) {
+			resp.Error = "invalid config"
+			resp.Errors = errs
+		}
+		logger.Warning("⚠️ Config update rejected: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+	_ = h.ConfigManager.SaveConfig()
+
 	oauth := newCfg.OAuthConfig
 	if oauth.ClientID != "" && oauth.ClientSecret != "" && oauth.RedirectURI != "" {
 		h.ConfigManager.SaveCredentialsFile(oauth.ClientID, oauth.ClientSecret, oauth.RedirectURI)
@@ -477,9 +516,6 @@ func (h *Handler) HandleConfigUpdate(w http.ResponseWriter, r *http.Request) {
 		go func() { _ = h.DriveInfo.InitOAuthConfig() }()
 	}
 
-	h.ConfigManager.UpdateConfig(newCfg)
-	_ = h.ConfigManager.SaveConfig()
-
 	if logChanged {
 		logger.InitLogging(&newCfg)
 	}
@@ -500,6 +536,63 @@ func (h *Handler) HandleConfigUpdate(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("ok"))
 }
 
+// preserveOmittedSections keeps config sections absent from the update payload
+func preserveOmittedSections(raw map[string]json.RawMessage, newCfg, oldCfg *model.Config) {
+	// Advanced keys missing from the payload (the dashboard only posts the log fields) keep their values
+	if _, ok := raw["advanced"]; !ok {
+		newCfg.Advanced = oldCfg.Advanced
+	} else {
+		advanced := oldCfg.Advanced
+		if err := json.Unmarshal(raw["advanced"], &advanced); err == nil {
+			advanced.TaskStats = oldCfg.Advanced.TaskStats
+			newCfg.Advanced = advanced
+		}
+	}
+	if _, ok := raw["rclone"]; !ok {
+		newCfg.Rclone = oldCfg.Rclone
+	} else if merged, ok := mergeRcloneInstances(raw["rclone"], oldCfg.Rclone); ok {
+		newCfg.Rclone = merged
+	}
+	if _, ok := raw["symedia"]; !ok {
+		newCfg.Symedia = oldCfg.Symedia
+	} else if merged, ok := mergeSymediaInstances(raw["symedia"], oldCfg.Symedia); ok {
+		newCfg.Symedia = merged
+	}
+	if _, ok := raw["kodi"]; !ok {
+		newCfg.Kodi = oldCfg.Kodi
+	}
+	if _, ok := raw["alist"]; !ok {
+		newCfg.Alist = oldCfg.Alist
+	}
+	if _, ok := raw["clouddrive2"]; !ok {
+		newCfg.CloudDrive2 = oldCfg.CloudDrive2
+	}
+	if _, ok := raw["webhooks"]; !ok {
+		newCfg.Webhooks = oldCfg.Webhooks
+	}
+	if _, ok := raw["exec"]; !ok {
+		newCfg.Exec = oldCfg.Exec
+	}
+	if _, ok := raw["strm"]; !ok {
+		newCfg.Strm = oldCfg.Strm
+	}
+	if _, ok := raw["crypt"]; !ok {
+		newCfg.Crypt = oldCfg.Crypt
+	}
+	if _, ok := raw["path_render"]; !ok {
+		newCfg.PathRender = oldCfg.PathRender
+	}
+	if _, ok := raw["filters"]; !ok {
+		newCfg.Filters = oldCfg.Filters
+	}
+	if _, ok := raw["routes"]; !ok {
+		newCfg.Routes = oldCfg.Routes
+	}
+	if _, ok := raw["mapping_sets"]; !ok {
+		newCfg.MappingSets = oldCfg.MappingSets
+	}
+}
+
 // HandleTrigger manually triggers sync
 func (h *Handler) HandleTrigger(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
@@ -528,11 +621,133 @@ func (h *Handler) HandleTestSymedia(w http.ResponseWriter, r *http.Request) {
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 			return
 		}
-		go h.Symedia.SendWebhook(p.Path, "create", false, "")
+		go h.Symedia.Test(p.Instance, p.Path)
 		_, _ = w.Write([]byte("ok"))
 	}
 }
 
+// HandleRcloneTest tests connection settings of an Rclone instance (saved by name, or as posted)
+func (h *Handler) HandleRcloneTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var inst model.RcloneInstance
+	if err := json.NewDecoder(r.Body).Decode(&inst); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if inst.Host == "" {
+		found := false
+		for _, saved := range h.ConfigManager.GetConfig().Rclone {
+			if saved.Name == inst.Name {
+				inst, found = saved, true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "Rclone instance not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	start := time.Now()
+	version, err := h.Rclone.Test(inst)
+	resp := model.RcloneTestResponse{OK: err == nil, Version: version, ElapsedMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		resp.Error = err.Error()
+		logger.Error("❌ [Rclone-%s] Connection test failed: %v", inst.Name, err)
+	} else {
+		logger.Info("✅ [Rclone-%s] Connection test passed (rclone %s)", inst.Name, version)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// HandleFilterTest explains which filter rules accept or reject a change, without processing it
+func (h *Handler) HandleFilterTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.FilterTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(service.ExplainFilters(h.ConfigManager, req))
+}
+
+// HandleRouteTest evaluates routing rules or a single expression against sample events
+func (h *Handler) HandleRouteTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.RouteTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Expression == "" && req.Routes == nil {
+		req.Routes = h.ConfigManager.GetConfig().Routes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(service.TestRoutes(req))
+}
+
+// HandleSymediaPreview renders the Symedia requests of a sample event without sending them
+func (h *Handler) HandleSymediaPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.TemplatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Event.Path == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Event.Action == "" {
+		req.Event.Action = model.ActionCreate
+	}
+	if req.Event.EventTime == "" {
+		req.Event.EventTime = time.Now().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Symedia.Preview(req.Event, req.Instance, req.MappingSet))
+}
+
+// HandleMappingTest shows how a path maps onto Symedia and every Rclone instance, without sending anything
+func (h *Handler) HandleMappingTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.MappingTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.Sync.TestMapping(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 // HandleWebhook handles Google Drive webhook callback
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	state := r.Header.Get("X-Goog-Resource-State")
@@ -629,3 +844,147 @@ func (h *Handler) HandleTreeRefresh(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status": "ok", "message": "Tree refresh started"}`))
 }
+
+// HandleStrmRegenerate rebuilds all .strm files from the file tree
+func (h *Handler) HandleStrmRegenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.ConfigManager.Lock.RLock()
+	strmCfg := h.ConfigManager.Cfg.Strm
+	h.ConfigManager.Lock.RUnlock()
+
+	if !strmCfg.Enabled || strmCfg.OutputDir == "" {
+		http.Error(w, "STRM generation is disabled", http.StatusBadRequest)
+		return
+	}
+
+	go func() {
+		if err := h.Strm.Regenerate(); err != nil {
+			logger.Error("❌ [STRM] Regenerate failed: %v", err)
+		}
+	}()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status": "ok", "message": "STRM regeneration started"}`))
+}
+
+// mergeRcloneInstances applies each posted rclone instance onto the stored instance with the same
+// host (or else at the same index), so fields the payload omits survive a save. The dashboard only
+// sends host, endpoint and mapping, and names its instances instance_<index>: those names keep the
+// stored name, which health state and groups are keyed by.
+func mergeRcloneInstances(raw json.RawMessage, old []model.RcloneInstance) ([]model.RcloneInstance, bool) {
+	var posted []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &posted); err != nil {
+		return nil, false
+	}
+
+	used := make(map[int]bool)
+	match := func(i int, host string) int {
+		for j, inst := range old {
+			if !used[j] && host != "" && inst.Host == host {
+				return j
+			}
+		}
+		if i < len(old) && !used[i] {
+			return i
+		}
+		return -1
+	}
+
+	merged := make([]model.RcloneInstance, 0, len(posted))
+	for i, fields := range posted {
+		var host, name string
+		_ = json.Unmarshal(fields["host"], &host)
+		_ = json.Unmarshal(fields["name"], &name)
+
+		var inst model.RcloneInstance
+		if j := match(i, host); j >= 0 {
+			used[j] = true
+			inst = old[j]
+			if name == "instance_"+strconv.Itoa(i) {
+				delete(fields, "name")
+			}
+			if _, ok := fields["headers"]; ok {
+				// Posted maps replace the stored one instead of being merged into it
+				inst.Headers = nil
+			}
+		}
+
+		item, _ := json.Marshal(fields)
+		if err := json.Unmarshal(item, &inst); err != nil {
+			return nil, false
+		}
+		merged = append(merged, inst)
+	}
+	return merged, true
+}
+
+// mergeSymediaInstances merges posted Symedia instances onto the stored instance with the same name (or
+// at the same index), so fields the payload leaves out (query, filters, mapping...) are kept. A single
+// object (the former format) is merged onto the first instance and keeps the others; without a host it
+// removes the first instance.
+func mergeSymediaInstances(raw json.RawMessage, old model.SymediaInstances) (model.SymediaInstances, bool) {
+	var posted []map[string]json.RawMessage
+	legacy := false
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &fields); err != nil {
+			return nil, false
+		}
+		posted, legacy = []map[string]json.RawMessage{fields}, true
+	} else if err := json.Unmarshal(raw, &posted); err != nil {
+		return nil, false
+	}
+
+	used := make(map[int]bool)
+	match := func(i int, name string) int {
+		for j, inst := range old {
+			if !used[j] && name != "" && inst.Name == name {
+				return j
+			}
+		}
+		if i < len(old) && !used[i] {
+			return i
+		}
+		return -1
+	}
+
+	merged := make(model.SymediaInstances, 0, len(posted))
+	for i, fields := range posted {
+		var name, host string
+		_ = json.Unmarshal(fields["name"], &name)
+		_ = json.Unmarshal(fields["host"], &host)
+		if legacy && host == "" {
+			// An object without a host was never set up
+			break
+		}
+
+		var inst model.SymediaInstance
+		if j := match(i, name); j >= 0 {
+			used[j] = true
+			inst = old[j]
+			// Posted maps replace the stored ones instead of being merged into them
+			if _, ok := fields["headers"]; ok {
+				inst.Headers = nil
+			}
+			if _, ok := fields["query"]; ok {
+				inst.Query = nil
+			}
+			if _, ok := fields["body_template"]; ok {
+				inst.BodyTemplate = nil
+			}
+		}
+
+		item, _ := json.Marshal(fields)
+		if err := json.Unmarshal(item, &inst); err != nil {
+			return nil, false
+		}
+		merged = append(merged, inst)
+	}
+	if legacy && len(old) > 0 {
+		merged = append(merged, old[1:]...)
+	}
+	return merged, true
+}
@@ -62,8 +62,14 @@ func (s *Server) Start() {
 	mux.HandleFunc("/api/config/update", s.Handler.HandleConfigUpdate)
 	mux.HandleFunc("/api/trigger", s.Handler.HandleTrigger)
 	mux.HandleFunc("/api/rclone_full", s.Handler.HandleRcloneFull)
+	mux.HandleFunc("/api/rclone/test", s.Handler.HandleRcloneTest)
+	mux.HandleFunc("/api/filters/test", s.Handler.HandleFilterTest)
+	mux.HandleFunc("/api/routes/test", s.Handler.HandleRouteTest)
+	mux.HandleFunc("/api/symedia/preview", s.Handler.HandleSymediaPreview)
+	mux.HandleFunc("/api/mapping/test", s.Handler.HandleMappingTest)
 	mux.HandleFunc("/api/test_symedia", s.Handler.HandleTestSymedia)
 	mux.HandleFunc("/api/tree/refresh", s.Handler.HandleTreeRefresh)
+	mux.HandleFunc("/api/strm/regenerate", s.Handler.HandleStrmRegenerate)
 
 	mux.HandleFunc(s.ConfigManager.Cfg.Server.WebhookPath, s.Handler.HandleWebhook)
 
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// AlistService handles Alist directory cache refresh
+type AlistService struct {
+	ConfigManager *config.Manager
+	LimitChan     chan struct{}
+}
+
+// NewAlistService creates a new Alist service
+func NewAlistService(cm *config.Manager) *AlistService {
+	return &AlistService{
+		ConfigManager: cm,
+		LimitChan:     make(chan struct{}, 5),
+	}
+}
+
+// alistResponse represents the common Alist API response envelope
+type alistResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Refresh lists the mapped directory with refresh=true on every matching Alist instance
+func (s *AlistService) Refresh(originPath string) {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Alist
+	regexRulesMap := s.ConfigManager.AlistRegexRules
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for idx, instance := range instances {
+		finalPath, matched, smartRoot := mapPathWithRules(originPath, regexRulesMap[idx])
+		if !matched {
+			continue
+		}
+		if smartRoot {
+			logger.Debug(logLevel, "🔍 [Alist-%s] Smart root match: %s -> %s", instance.Name, originPath, finalPath)
+		} else {
+			logger.Debug(logLevel, "🔍 [Alist-%s] Regex matched: %s -> %s", instance.Name, originPath, finalPath)
+		}
+
+		wg.Add(1)
+		go func(inst model.AlistInstance, dir string) {
+			defer wg.Done()
+
+			// Limit concurrent requests
+			s.LimitChan <- struct{}{}
+			defer func() { <-s.LimitChan }()
+
+			logger.Info("🔄 [Alist-%s] Refreshing: %s", inst.Name, dir)
+			if err := s.refreshDir(inst, dir, logLevel); err != nil {
+				logger.Error("❌ [Alist-%s] Refresh failed: %v", inst.Name, err)
+				return
+			}
+			logger.Info("✅ [Alist-%s] Refresh successful", inst.Name)
+		}(instance, finalPath)
+	}
+	wg.Wait()
+}
+
+// refreshDir calls fs/list with refresh=true for a single directory
+func (s *AlistService) refreshDir(inst model.AlistInstance, dir string, logLevel int) error {
+	payload := map[string]interface{}{
+		"path":     dir,
+		"password": inst.Password,
+		"page":     1,
+		"per_page": 1,
+		"refresh":  true,
+	}
+	data, _ := json.Marshal(payload)
+
+	fullURL := strings.TrimRight(inst.Host, "/") + "/api/fs/list"
+	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if inst.Token != "" {
+		req.Header.Set("Authorization", inst.Token)
+	}
+
+	if logLevel >= model.LogLevelDebug {
+		logger.Debug(logLevel, "   👉 URL: %s", fullURL)
+		logger.Debug(logLevel, "   👉 Body: %s", string(data))
+	}
+
+	timeout := inst.Timeout
+	if timeout <= 0 {
+		timeout = 60
+	}
+	cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if logLevel >= model.LogLevelDebug {
+		logger.Debug(logLevel, "   👈 Response Status: %s", resp.Status)
+		logger.Debug(logLevel, "   👈 Response Body: %s", string(respBody))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	// Alist reports errors inside a 200 response
+	var ar alistResponse
+	if err := json.Unmarshal(respBody, &ar); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if ar.Code != 200 {
+		return fmt.Errorf("code %d: %s", ar.Code, ar.Message)
+	}
+	return nil
+}
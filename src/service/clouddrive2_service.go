@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// cd2ExpireDirCacheMethod is the gRPC method that drops CloudDrive2's cached listing of a directory
+const cd2ExpireDirCacheMethod = "/clouddrive.CloudDriveFileSrv/ForceExpireDirCache"
+
+// CloudDrive2Service handles CloudDrive2 directory cache refresh
+type CloudDrive2Service struct {
+	ConfigManager *config.Manager
+	LimitChan     chan struct{}
+}
+
+// NewCloudDrive2Service creates a new CloudDrive2 service
+func NewCloudDrive2Service(cm *config.Manager) *CloudDrive2Service {
+	return &CloudDrive2Service{
+		ConfigManager: cm,
+		LimitChan:     make(chan struct{}, 5),
+	}
+}
+
+// Refresh expires the directory cache of the mapped path on every matching CloudDrive2 instance
+func (s *CloudDrive2Service) Refresh(originPath string) {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.CloudDrive2
+	regexRulesMap := s.ConfigManager.CD2RegexRules
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for idx, instance := range instances {
+		finalPath, matched, smartRoot := mapPathWithRules(originPath, regexRulesMap[idx])
+		if !matched {
+			continue
+		}
+		if smartRoot {
+			logger.Debug(logLevel, "🔍 [CD2-%s] Smart root match: %s -> %s", instance.Name, originPath, finalPath)
+		} else {
+			logger.Debug(logLevel, "🔍 [CD2-%s] Regex matched: %s -> %s", instance.Name, originPath, finalPath)
+		}
+
+		wg.Add(1)
+		go func(inst model.CloudDrive2Instance, dir string) {
+			defer wg.Done()
+
+			// Limit concurrent requests
+			s.LimitChan <- struct{}{}
+			defer func() { <-s.LimitChan }()
+
+			logger.Info("🔄 [CD2-%s] Refreshing: %s", inst.Name, dir)
+			if err := s.expireDirCache(inst, dir, logLevel); err != nil {
+				logger.Error("❌ [CD2-%s] Refresh failed: %v", inst.Name, err)
+				return
+			}
+			logger.Info("✅ [CD2-%s] Refresh successful", inst.Name)
+		}(instance, finalPath)
+	}
+	wg.Wait()
+}
+
+// expireDirCache calls ForceExpireDirCache over gRPC-Web (no generated stubs required)
+func (s *CloudDrive2Service) expireDirCache(inst model.CloudDrive2Instance, dir string, logLevel int) error {
+	// FileRequest { string path = 1; }
+	msg := appendProtoString(nil, 1, dir)
+
+	// gRPC-Web frame: 1 byte flags + 4 bytes big-endian length + message
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	fullURL := strings.TrimRight(inst.Host, "/") + cd2ExpireDirCacheMethod
+	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(frame))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	if inst.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+inst.Token)
+	}
+
+	logger.Debug(logLevel, "   👉 URL: %s (path=%s)", fullURL, dir)
+
+	timeout := inst.Timeout
+	if timeout <= 0 {
+		timeout = 60
+	}
+	cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	logger.Debug(logLevel, "   👈 Response Status: %s", resp.Status)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	// Status is either in the headers (trailers-only response) or in the trailer frame of the body
+	status, message := resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	if status == "" {
+		status, message = parseGrpcWebTrailers(respBody)
+	}
+	if status != "" && status != "0" {
+		return fmt.Errorf("grpc-status %s: %s", status, message)
+	}
+	return nil
+}
+
+// appendProtoString appends a length-delimited protobuf string field
+func appendProtoString(b []byte, field int, value string) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// parseGrpcWebTrailers extracts grpc-status and grpc-message from gRPC-Web trailer frames
+func parseGrpcWebTrailers(body []byte) (string, string) {
+	var status, message string
+	for len(body) >= 5 {
+		flags := body[0]
+		n := int(binary.BigEndian.Uint32(body[1:5]))
+		if 5+n > len(body) {
+			break
+		}
+		payload := body[5 : 5+n]
+		body = body[5+n:]
+
+		if flags&0x80 == 0 {
+			continue
+		}
+		for _, line := range strings.Split(string(payload), "\r\n") {
+			k, v, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(k)) {
+			case "grpc-status":
+				status = strings.TrimSpace(v)
+			case "grpc-message":
+				message = strings.TrimSpace(v)
+			}
+		}
+	}
+	return status, message
+}
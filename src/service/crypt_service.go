@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// CryptService decrypts rclone crypt names in paths produced by the FileTree
+type CryptService struct {
+	ConfigManager *config.Manager
+
+	mu      sync.Mutex
+	ciphers map[string]*rcloneNameCipher // Key material -> cipher (scrypt is slow, derive once)
+}
+
+// NewCryptService creates a new crypt service
+func NewCryptService(cm *config.Manager) *CryptService {
+	return &CryptService{
+		ConfigManager: cm,
+		ciphers:       make(map[string]*rcloneNameCipher),
+	}
+}
+
+// DecryptPath returns the decrypted form of a tree path. Paths outside every
+// crypt rule, and segments that fail to decrypt, are returned unchanged.
+func (s *CryptService) DecryptPath(path, driveID string, isDir bool) string {
+	s.ConfigManager.Lock.RLock()
+	rules := s.ConfigManager.Cfg.Crypt
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	if len(rules) == 0 {
+		return path
+	}
+
+	rule, ok := matchCryptRule(rules, path, driveID)
+	if !ok {
+		return path
+	}
+
+	c, err := s.cipher(rule)
+	if err != nil {
+		logger.Error("❌ [Crypt-%s] Invalid settings: %v", rule.Name, err)
+		return path
+	}
+
+	prefix := strings.TrimRight(rule.PathPrefix, "/")
+	rest := strings.TrimPrefix(path[len(prefix):], "/")
+	if rest == "" {
+		return path
+	}
+
+	decryptDirs := rule.DirectoryNameEncryption == nil || *rule.DirectoryNameEncryption
+	segments := strings.Split(rest, "/")
+	for i, seg := range segments {
+		isLast := i == len(segments)-1
+		var plain string
+		var err error
+		switch {
+		case isLast && !isDir:
+			plain, err = c.DecryptFileName(seg)
+		case decryptDirs:
+			plain, err = c.DecryptDirName(seg)
+		default:
+			continue
+		}
+		if err != nil {
+			logger.Debug(logLevel, "🔓 [Crypt-%s] Cannot decrypt segment %q: %v", rule.Name, seg, err)
+			continue
+		}
+		segments[i] = plain
+	}
+
+	decrypted := prefix + "/" + strings.Join(segments, "/")
+	logger.Debug(logLevel, "🔓 [Crypt-%s] %s -> %s", rule.Name, path, decrypted)
+	return decrypted
+}
+
+// matchCryptRule picks the rule with the longest matching path prefix
+func matchCryptRule(rules []model.CryptRule, path, driveID string) (model.CryptRule, bool) {
+	var best model.CryptRule
+	bestLen := -1
+	for _, r := range rules {
+		if r.DriveID != "" && r.DriveID != driveID && !(r.DriveID == "root" && driveID == "") {
+			continue
+		}
+		prefix := strings.TrimRight(r.PathPrefix, "/")
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if len(prefix) > bestLen {
+			best, bestLen = r, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// cipher returns the cached name cipher of a rule, deriving keys on first use
+func (s *CryptService) cipher(rule model.CryptRule) (*rcloneNameCipher, error) {
+	key := fmt.Sprintf("%s\x00%s\x00%v\x00%s\x00%s", rule.Password, rule.Salt, rule.Obscured, rule.FilenameEncryption, rule.FilenameEncoding)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.ciphers[key]; ok {
+		return c, nil
+	}
+
+	password, salt := rule.Password, rule.Salt
+	if rule.Obscured {
+		var err error
+		if password, err = revealRclonePassword(password); err != nil {
+			return nil, fmt.Errorf("password: %w", err)
+		}
+		if salt != "" {
+			if salt, err = revealRclonePassword(salt); err != nil {
+				return nil, fmt.Errorf("salt: %w", err)
+			}
+		}
+	}
+
+	c, err := newRcloneNameCipher(password, salt, rule.FilenameEncryption, rule.FilenameEncoding)
+	if err != nil {
+		return nil, err
+	}
+	s.ciphers[key] = c
+	return c, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"time"
+
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// heldDeletes is the set of delete events of one sync run waiting for the grace window to end
+type heldDeletes struct {
+	events map[string]model.ChangeEvent // File ID -> delete event
+	order  []string                     // File IDs in original event order
+}
+
+// holdDeletes removes delete events from the batch and holds them for advanced.delete_grace_seconds.
+// A create of a held file ID within the window turns the delete into a move (a directory's contents
+// move with it through its descendants); unclaimed deletes are dispatched when the window ends.
+func (s *SyncService) holdDeletes(events []model.ChangeEvent) []model.ChangeEvent {
+	s.ConfigManager.Lock.RLock()
+	grace := time.Duration(s.ConfigManager.Cfg.Advanced.DeleteGraceSeconds) * time.Second
+	s.ConfigManager.Lock.RUnlock()
+
+	s.graceMu.Lock()
+	defer s.graceMu.Unlock()
+
+	if grace <= 0 && len(s.graceHeld) == 0 {
+		return events
+	}
+
+	batch := &heldDeletes{events: make(map[string]model.ChangeEvent)}
+	out := make([]model.ChangeEvent, 0, len(events))
+	for _, ev := range events {
+		switch {
+		case ev.Action == model.ActionDelete && grace > 0 && ev.FileID != "":
+			batch.events[ev.FileID] = ev
+			batch.order = append(batch.order, ev.FileID)
+			s.graceHeld[ev.FileID] = batch
+		case ev.Action == model.ActionCreate && s.graceHeld[ev.FileID] != nil:
+			out = append(out, s.reclaimDelete(ev)...)
+		default:
+			out = append(out, ev)
+		}
+	}
+
+	if len(batch.order) > 0 {
+		logger.Info("⏸️ [Grace] Holding %d delete notifications for %v", len(batch.order), grace)
+		time.AfterFunc(grace, func() { s.releaseDeletes(batch) })
+	}
+	return out
+}
+
+// reclaimDelete turns the held delete of a reappeared file into a move event (caller must hold graceMu)
+func (s *SyncService) reclaimDelete(create model.ChangeEvent) []model.ChangeEvent {
+	held := s.takeHeld(create.FileID)
+	if held.Path == create.Path {
+		logger.Info("♻️ [Grace] %s reappeared at the same path, dropping delete", create.Path)
+		return nil
+	}
+
+	logger.Info("♻️ [Grace] %s reappeared as %s, reporting a move", held.Path, create.Path)
+	move := create
+	move.Action = model.ActionMove
+	move.OldPath = held.Path
+	// The contents of a reappeared directory come back with it
+	move.Descendants = held.Descendants
+	return []model.ChangeEvent{move}
+}
+
+// takeHeld removes a held delete and returns it (caller must hold graceMu)
+func (s *SyncService) takeHeld(fileID string) model.ChangeEvent {
+	b := s.graceHeld[fileID]
+	ev := b.events[fileID]
+	delete(b.events, fileID)
+	delete(s.graceHeld, fileID)
+	return ev
+}
+
+// releaseDeletes dispatches the deletes of a batch that were not reclaimed within the window
+func (s *SyncService) releaseDeletes(batch *heldDeletes) {
+	s.graceMu.Lock()
+	var released []model.ChangeEvent
+	for _, id := range batch.order {
+		ev, ok := batch.events[id]
+		if !ok {
+			continue
+		}
+		released = append(released, ev)
+		delete(batch.events, id)
+		if s.graceHeld[id] == batch {
+			delete(s.graceHeld, id)
+		}
+	}
+	s.graceMu.Unlock()
+
+	if len(released) == 0 {
+		return
+	}
+	logger.Info("🗑️ [Grace] Window ended, sending %d held delete notifications", len(released))
+	s.scheduleDispatch(nil, released)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"sync"
@@ -25,7 +26,6 @@ import (
 type DriveService struct {
 	Srv            *drive.Service
 	Limiter        *rate.Limiter
-	DriveNameCache sync.Map
 	DriveCacheLoad sync.Map
 	OAuthConfig    *oauth2.Config
 	ConfigManager  *config.Manager
@@ -131,22 +131,26 @@ func (s *DriveService) WaitRateLimit() {
 	}
 }
 
+// DriveRoot returns the root of a drive's paths: the root set in google.drive_roots, or /<drive name>
+func (s *DriveService) DriveRoot(driveID string) string {
+	if root := s.ConfigManager.FixedDriveRoot(driveID); root != "" {
+		return root
+	}
+	return "/" + s.GetDriveName(driveID)
+}
+
 // GetDriveName gets drive name (with cache)
 func (s *DriveService) GetDriveName(driveID string) string {
 	if driveID == "" {
 		cfg := s.ConfigManager.GetConfig()
-		name := cfg.Google.PersonalDriveName
-		if name == "" {
-			return "Cloud Drive"
-		}
-		return name
+		return config.PersonalDriveName(&cfg)
 	}
-	if val, ok := s.DriveNameCache.Load(driveID); ok {
+	if val, ok := s.ConfigManager.DriveNames.Load(driveID); ok {
 		return val.(string)
 	}
 	if _, loaded := s.DriveCacheLoad.LoadOrStore(driveID, true); loaded {
 		time.Sleep(100 * time.Millisecond)
-		if val, ok := s.DriveNameCache.Load(driveID); ok {
+		if val, ok := s.ConfigManager.DriveNames.Load(driveID); ok {
 			return val.(string)
 		}
 		return driveID
@@ -162,17 +166,35 @@ func (s *DriveService) GetDriveName(driveID string) string {
 	if err != nil {
 		if strings.Contains(err.Error(), "403") || strings.Contains(err.Error(), "insufficient authentication scopes") {
 			logger.Warning("⚠️ Insufficient permissions to get shared drive name (ID: %s), showing ID.", driveID)
-			s.DriveNameCache.Store(driveID, driveID)
+			s.ConfigManager.DriveNames.Store(driveID, driveID)
 			return driveID
 		}
 		logger.Error("Failed to get shared drive (ID: %s): %v", driveID, err)
 		return driveID
 	}
-	s.DriveNameCache.Store(driveID, d.Name)
+	s.ConfigManager.DriveNames.Store(driveID, d.Name)
 	logger.Verbose(model.LogLevelInfo, "💾 Found new shared drive: [%s] -> %s", driveID, d.Name)
 	return d.Name
 }
 
+// GetFileState fetches the current size, checksum and trashed state of a file
+func (s *DriveService) GetFileState(fileID string) (*drive.File, error) {
+	if s.Srv == nil {
+		return nil, errors.New("drive service not initialized")
+	}
+	var f *drive.File
+	err := s.retryRequest(func() error {
+		s.WaitRateLimit()
+		var err error
+		f, err = s.Srv.Files.Get(fileID).
+			Fields("id, size, md5Checksum, modifiedTime, trashed").
+			SupportsAllDrives(true).
+			Do()
+		return err
+	})
+	return f, err
+}
+
 // ListAllDrives lists all shared drives
 func (s *DriveService) ListAllDrives() ([]*drive.Drive, error) {
 	s.WaitRateLimit()
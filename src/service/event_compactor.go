@@ -0,0 +1,192 @@
+package service
+
+import (
+	"time"
+
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// CompactionStats counts what the event compaction folded away
+type CompactionStats struct {
+	EventsIn        int64 `json:"events_in"`
+	EventsOut       int64 `json:"events_out"`
+	CreateDeleted   int64 `json:"create_deleted"`   // create + delete => nothing
+	CreateMoved     int64 `json:"create_moved"`     // create + move(s) => create at final path
+	MovesMerged     int64 `json:"moves_merged"`     // move chains => one move (or nothing when moved back)
+	MoveDeleted     int64 `json:"move_deleted"`     // move(s) + delete => delete at original path
+	ModifiesMerged  int64 `json:"modifies_merged"`  // repeated modifies => one
+	DeleteRecreated int64 `json:"delete_recreated"` // delete + create => move (or modify at the same path)
+}
+
+// compactEvents folds the events of a sync run, either immediately or after buffering them
+// for advanced.event_compaction_seconds together with the following runs
+func (s *SyncService) compactEvents(events []model.ChangeEvent) {
+	s.ConfigManager.Lock.RLock()
+	window := time.Duration(s.ConfigManager.Cfg.Advanced.EventCompactionSeconds) * time.Second
+	s.ConfigManager.Lock.RUnlock()
+
+	s.compactMu.Lock()
+	if window > 0 || len(s.compactPending) > 0 {
+		first := len(s.compactPending) == 0
+		s.compactPending = append(s.compactPending, events...)
+		s.compactMu.Unlock()
+		if first {
+			logger.Info("⏸️ [Compact] Buffering events for %v", window)
+			time.AfterFunc(window, s.flushCompaction)
+		}
+		return
+	}
+	s.compactMu.Unlock()
+
+	if net := s.holdUnstable(s.applyGlobalFilter(s.compact(events))); len(net) > 0 {
+		s.processEvents(net)
+	}
+}
+
+// flushCompaction processes the buffered events once the window ends
+func (s *SyncService) flushCompaction() {
+	s.compactMu.Lock()
+	events := s.compactPending
+	s.compactPending = nil
+	s.compactMu.Unlock()
+
+	if net := s.holdUnstable(s.applyGlobalFilter(s.compact(events))); len(net) > 0 {
+		s.processEvents(net)
+	}
+}
+
+// compact reduces the events to their net effect per file ID and updates the counters
+func (s *SyncService) compact(events []model.ChangeEvent) []model.ChangeEvent {
+	var order []string
+	byID := make(map[string][]model.ChangeEvent)
+	var out []model.ChangeEvent
+	for _, ev := range events {
+		if ev.FileID == "" {
+			continue
+		}
+		if _, ok := byID[ev.FileID]; !ok {
+			order = append(order, ev.FileID)
+		}
+		byID[ev.FileID] = append(byID[ev.FileID], ev)
+	}
+
+	var stats CompactionStats
+	for _, id := range order {
+		if ev, ok := foldEvents(byID[id], &stats); ok {
+			out = append(out, ev)
+		}
+	}
+	// Events without a file ID cannot be folded
+	for _, ev := range events {
+		if ev.FileID == "" {
+			out = append(out, ev)
+		}
+	}
+
+	stats.EventsIn = int64(len(events))
+	stats.EventsOut = int64(len(out))
+	if stats.EventsIn != stats.EventsOut {
+		logger.Info("🧩 [Compact] %d events -> %d", stats.EventsIn, stats.EventsOut)
+	}
+
+	s.compactMu.Lock()
+	s.compactStats.EventsIn += stats.EventsIn
+	s.compactStats.EventsOut += stats.EventsOut
+	s.compactStats.CreateDeleted += stats.CreateDeleted
+	s.compactStats.CreateMoved += stats.CreateMoved
+	s.compactStats.MovesMerged += stats.MovesMerged
+	s.compactStats.MoveDeleted += stats.MoveDeleted
+	s.compactStats.ModifiesMerged += stats.ModifiesMerged
+	s.compactStats.DeleteRecreated += stats.DeleteRecreated
+	s.compactMu.Unlock()
+	return out
+}
+
+// foldEvents returns the net event of one file ID's events (false if they cancel out)
+func foldEvents(evs []model.ChangeEvent, stats *CompactionStats) (model.ChangeEvent, bool) {
+	first, last := evs[0], evs[len(evs)-1]
+	if len(evs) == 1 {
+		return first, true
+	}
+
+	existedBefore := first.Action != model.ActionCreate
+	existsAfter := last.Action != model.ActionDelete
+	origPath := first.Path
+	if first.Action == model.ActionMove {
+		origPath = first.OldPath
+	}
+
+	moves, modifies, recreated := 0, 0, false
+	for i, ev := range evs {
+		switch ev.Action {
+		case model.ActionMove:
+			moves++
+		case model.ActionModify:
+			modifies++
+		case model.ActionCreate:
+			recreated = recreated || i > 0
+		}
+	}
+
+	net := last
+	// A recreated directory keeps the descendants captured when it was moved or deleted
+	for i := len(evs) - 1; i >= 0 && net.Descendants == nil; i-- {
+		net.Descendants = evs[i].Descendants
+	}
+	switch {
+	case !existedBefore && !existsAfter:
+		stats.CreateDeleted++
+		return net, false
+
+	case !existedBefore:
+		net.Action = model.ActionCreate
+		net.OldPath = ""
+		if moves > 0 {
+			stats.CreateMoved++
+		}
+		if modifies > 0 {
+			stats.ModifiesMerged++
+		}
+		return net, true
+
+	case !existsAfter:
+		net.Path = origPath
+		net.OldPath = ""
+		if moves > 0 {
+			stats.MoveDeleted++
+		}
+		return net, true
+	}
+
+	if recreated {
+		stats.DeleteRecreated++
+	}
+	if origPath != last.Path {
+		net.Action = model.ActionMove
+		net.OldPath = origPath
+		if moves > 1 || modifies > 0 {
+			stats.MovesMerged++
+		}
+		return net, true
+	}
+	if modifies > 0 || recreated {
+		net.Action = model.ActionModify
+		net.OldPath = ""
+		if modifies > 1 {
+			stats.ModifiesMerged++
+		}
+		return net, true
+	}
+
+	// Moved away and back
+	stats.MovesMerged++
+	return net, false
+}
+
+// GetCompactionStats returns the compaction counters since startup
+func (s *SyncService) GetCompactionStats() CompactionStats {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+	return s.compactStats
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/model"
+)
+
+// Sink keys used in routes
+const (
+	RouteSinkSymedia = "symedia"
+	RouteSinkStrm    = "strm"
+	RouteSinkKodi    = "kodi"
+)
+
+// SymediaSinkKey returns the route sink key of a Symedia instance; "symedia" selects every instance
+func SymediaSinkKey(name string) string { return RouteSinkSymedia + ":" + name }
+
+// matchRoute returns the index of the first route whose compiled expression matches the event (-1 if none).
+// Routes that do not compile (nil) never match; they are rejected when the config is saved.
+func matchRoute(exprs []*routeExpr, ev model.ChangeEvent) int {
+	for i, expr := range exprs {
+		if expr != nil && expr.Match(ev) {
+			return i
+		}
+	}
+	return -1
+}
+
+// routeEvents keeps the events routed to a sink; events no route matches go to every sink
+func routeEvents(cm *config.Manager, events []model.ChangeEvent, sink string) []model.ChangeEvent {
+	cm.Lock.RLock()
+	routes := cm.Cfg.Routes
+	cm.Lock.RUnlock()
+
+	if len(routes) == 0 {
+		return events
+	}
+	exprs := configuredRouteExprs(routes)
+	out := make([]model.ChangeEvent, 0, len(events))
+	for _, ev := range events {
+		if idx := matchRoute(exprs, ev); idx < 0 || routeHasSink(routes[idx], sink) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// sinkEvents returns the events a sink receives: descendants expanded per its subtree policy,
+// then the global and sink filters, then the routes
+func sinkEvents(cm *config.Manager, events []model.ChangeEvent, policy string, filter model.FilterConfig, patterns []config.FilterPattern, sink string) []model.ChangeEvent {
+	return routeEvents(cm, filterEvents(cm, applySubtreePolicy(events, policy), sink, filter, patterns), sink)
+}
+
+// routeMappingSet returns the mapping set the routes select for an event ("" = path_mapping)
+func routeMappingSet(cm *config.Manager, ev model.ChangeEvent) string {
+	cm.Lock.RLock()
+	routes := cm.Cfg.Routes
+	cm.Lock.RUnlock()
+
+	if idx := matchRoute(configuredRouteExprs(routes), ev); idx >= 0 {
+		return routes[idx].MappingSet
+	}
+	return ""
+}
+
+// routeHasSink reports whether a route sends its events to a sink
+func routeHasSink(route model.RouteRule, sink string) bool {
+	for _, s := range route.Sinks {
+		if s == sink || (s == RouteSinkSymedia && strings.HasPrefix(sink, RouteSinkSymedia+":")) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	config.RegisterValidator(validateRoutes)
+}
+
+// validateRoutes checks the expressions, sinks and mapping sets of the routes in a config
+func validateRoutes(cfg *model.Config) []model.FieldError {
+	sinks := map[string]bool{RouteSinkSymedia: true, RouteSinkStrm: true, RouteSinkKodi: true}
+	for _, sink := range cfg.Symedia {
+		sinks[SymediaSinkKey(sink.Name)] = true
+	}
+	for _, sink := range cfg.Webhooks {
+		sinks["webhook:"+sink.Name] = true
+	}
+	for _, sink := range cfg.Exec {
+		sinks["exec:"+sink.Name] = true
+	}
+
+	var errs []model.FieldError
+	for i, route := range cfg.Routes {
+		field := fmt.Sprintf("routes[%d]", i)
+		if strings.TrimSpace(route.When) == "" {
+			errs = append(errs, model.FieldError{Field: field + ".when", Message: "expression is empty"})
+		} else if _, err := compileRouteExpr(route.When); err != nil {
+			errs = append(errs, model.FieldError{Field: field + ".when", Message: err.Error()})
+		}
+		for j, sink := range route.Sinks {
+			if !sinks[sink] {
+				errs = append(errs, model.FieldError{Field: fmt.Sprintf("%s.sinks[%d]", field, j), Message: fmt.Sprintf("unknown sink %q", sink)})
+			}
+		}
+		if route.MappingSet != "" {
+			if _, ok := cfg.MappingSets[route.MappingSet]; !ok {
+				errs = append(errs, model.FieldError{Field: field + ".mapping_set", Message: fmt.Sprintf("unknown mapping set %q", route.MappingSet)})
+			}
+		}
+	}
+	return errs
+}
+
+// TestRoutes evaluates an expression, or the given routes, against sample events
+func TestRoutes(req model.RouteTestRequest) []model.RouteTestResult {
+	results := make([]model.RouteTestResult, 0, len(req.Events))
+
+	if req.Expression != "" {
+		expr, err := compileRouteExpr(req.Expression)
+		for _, ev := range req.Events {
+			res := model.RouteTestResult{Path: ev.Path, Route: -1}
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Matched = expr.Match(ev)
+			}
+			results = append(results, res)
+		}
+		return results
+	}
+
+	// Posted routes are compiled for this test only, not cached
+	exprs := compileRoutes(req.Routes)
+	for _, ev := range req.Events {
+		res := model.RouteTestResult{Path: ev.Path, Route: matchRoute(exprs, ev)}
+		if res.Route >= 0 {
+			route := req.Routes[res.Route]
+			res.Matched = true
+			res.RouteName = route.Name
+			res.Sinks = append([]string{}, route.Sinks...)
+			res.MappingSet = route.MappingSet
+		}
+		results = append(results, res)
+	}
+	return results
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package service
+
+import "os/exec"
+
+// killProcessGroup keeps the default cancel on Windows (the process is killed, cmd.WaitDelay
+// releases the pipes children keep open)
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// ExecService runs local commands on change events
+type ExecService struct {
+	ConfigManager *config.Manager
+
+	mu    sync.Mutex
+	slots map[string]chan struct{} // Sink name -> concurrency limiter
+}
+
+// NewExecService creates a new exec service
+func NewExecService(cm *config.Manager) *ExecService {
+	return &ExecService{
+		ConfigManager: cm,
+		slots:         make(map[string]chan struct{}),
+	}
+}
+
+// Send runs every configured command for the matching events
+func (s *ExecService) Send(events []model.ChangeEvent) {
+	s.ConfigManager.Lock.RLock()
+	sinks := s.ConfigManager.Cfg.Exec
+	filterRulesMap := s.ConfigManager.ExecFilterRules
+	patterns := s.ConfigManager.FilterPatterns
+	s.ConfigManager.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for idx, sink := range sinks {
+		if sink.Command == "" {
+			continue
+		}
+
+		var matched []model.ChangeEvent
+		for _, ev := range sinkEvents(s.ConfigManager, events, sink.SubtreePolicy, sink.Filters, patterns[config.ExecFilterScope(idx)], "exec:"+sink.Name) {
+			if matchExecFilters(ev, filterRulesMap[idx]) {
+				matched = append(matched, ev)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if sink.Batch {
+			wg.Add(1)
+			go func(sk model.ExecSink, evs []model.ChangeEvent) {
+				defer wg.Done()
+				s.run(sk, evs)
+			}(sink, matched)
+			continue
+		}
+
+		for _, ev := range matched {
+			wg.Add(1)
+			go func(sk model.ExecSink, e model.ChangeEvent) {
+				defer wg.Done()
+				s.run(sk, []model.ChangeEvent{e})
+			}(sink, ev)
+		}
+	}
+	wg.Wait()
+}
+
+// matchExecFilters reports whether the event path (or old path) matches any filter
+func matchExecFilters(ev model.ChangeEvent, rules []*regexp.Regexp) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		if r.MatchString(ev.Path) || (ev.OldPath != "" && r.MatchString(ev.OldPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// slot returns the concurrency limiter of a sink, resizing it if the config changed
+func (s *ExecService) slot(sink model.ExecSink) chan struct{} {
+	limit := sink.Concurrency
+	if limit <= 0 {
+		limit = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.slots[sink.Name]
+	if !ok || cap(ch) != limit {
+		ch = make(chan struct{}, limit)
+		s.slots[sink.Name] = ch
+	}
+	return ch
+}
+
+// run executes the command once with the given events on stdin and in the environment
+func (s *ExecService) run(sink model.ExecSink, events []model.ChangeEvent) {
+	limiter := s.slot(sink)
+	limiter <- struct{}{}
+	defer func() { <-limiter }()
+
+	timeout := sink.Timeout
+	if timeout <= 0 {
+		timeout = 300
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", sink.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", sink.Command)
+	}
+	killProcessGroup(cmd)
+	// Don't wait for children holding stdout/stderr open after the command was killed
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Dir = sink.WorkDir
+	cmd.Env = append(os.Environ(), execEnv(sink, events)...)
+
+	stdin, _ := json.Marshal(model.EventEnvelope{
+		SchemaVersion: model.EventSchemaVersion,
+		DeliveryID:    uuid.New().String(),
+		SentAt:        time.Now().Format(time.RFC3339),
+		Source:        config.GetAppName(),
+		Events:        events,
+	})
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	label := events[0].Path
+	if len(events) > 1 {
+		label = fmt.Sprintf("%d events", len(events))
+	}
+	logger.Info("⚙️ [Exec-%s] Running for %s", sink.Name, label)
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	logCommandOutput(sink.Name, "stdout", stdout.String())
+	logCommandOutput(sink.Name, "stderr", stderr.String())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Error("❌ [Exec-%s] Timed out after %v", sink.Name, elapsed)
+		return
+	}
+	if err != nil {
+		logger.Error("❌ [Exec-%s] Failed after %v: %v", sink.Name, elapsed, err)
+		return
+	}
+	logger.Info("✅ [Exec-%s] Finished in %v", sink.Name, elapsed)
+}
+
+// execEnv builds the GDW_* environment variables describing the events
+func execEnv(sink model.ExecSink, events []model.ChangeEvent) []string {
+	env := make([]string, 0, len(sink.Env)+12)
+	for k, v := range sink.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env,
+		"GDW_EVENT_COUNT="+strconv.Itoa(len(events)),
+		"GDW_SYNC_RUN_ID="+events[0].SyncRunID,
+	)
+
+	// Per-event fields are only meaningful when a single event is passed
+	if len(events) == 1 {
+		ev := events[0]
+		env = append(env,
+			"GDW_ACTION="+ev.Action,
+			"GDW_PATH="+ev.Path,
+			"GDW_OLD_PATH="+ev.OldPath,
+			"GDW_FILE_ID="+ev.FileID,
+			"GDW_DRIVE_ID="+ev.DriveID,
+			"GDW_DRIVE_NAME="+ev.DriveName,
+			"GDW_IS_DIR="+strconv.FormatBool(ev.IsDir),
+			"GDW_MIME_TYPE="+ev.MimeType,
+			"GDW_SIZE="+strconv.FormatInt(ev.Size, 10),
+			"GDW_EVENT_TIME="+ev.EventTime,
+		)
+	}
+	return env
+}
+
+// logCommandOutput writes captured command output to the log line by line
+func logCommandOutput(name, stream, output string) {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return
+	}
+	sc := bufio.NewScanner(strings.NewReader(output))
+	for sc.Scan() {
+		if stream == "stderr" {
+			logger.Warning("   [Exec-%s] %s: %s", name, stream, sc.Text())
+		} else {
+			logger.Info("   [Exec-%s] %s: %s", name, stream, sc.Text())
+		}
+	}
+}
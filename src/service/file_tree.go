@@ -10,6 +10,7 @@ import (
 	"runtime/debug"
 	"sync"
 
+	"gd-webhook/src/config"
 	"gd-webhook/src/logger"
 	"gd-webhook/src/model"
 )
@@ -270,38 +271,43 @@ func (t *FileTree) GetPath(id string) (string, bool) {
 
 // getPathLocked internal recursive path resolution
 func (t *FileTree) getPathLocked(id string) (string, bool) {
+	return t.renderPathLocked(id, t.ds.ConfigManager.NameRenderer())
+}
+
+// renderPathLocked resolves the path of a node, rendering every name with the path_render settings
+func (t *FileTree) renderPathLocked(id string, render *config.NameRenderer) (string, bool) {
 	node, ok := t.nodes[id]
 	if !ok {
 		return "", false
 	}
 
 	if node.ParentID == "" {
-		driveName := t.ds.GetDriveName(node.DriveID)
+		root := t.ds.DriveRoot(node.DriveID)
 
 		// [Fix] If this is a shared drive's root node (ID == DriveID)
-		// Return /DriveName directly to avoid duplication (e.g. /DriveName/DriveName)
+		// Return the drive root directly to avoid duplication (e.g. /DriveName/DriveName)
 		if node.DriveID != "" && node.ID == node.DriveID {
-			return "/" + driveName, true
+			return root, true
 		}
 
 		// [Fix] If this is My Drive's root node (ID == "root")
-		// Return /DriveName directly
+		// Return the drive root directly
 		if node.ID == "root" {
-			return "/" + driveName, true
+			return root, true
 		}
 
 		// Other cases (e.g. orphan files in My Drive, or weird structure)
-		return "/" + driveName + "/" + node.Name, true
+		return filepath.Join(root, render.Render(node.Name)), true
 	}
 
-	parentPath, parentOk := t.getPathLocked(node.ParentID)
+	parentPath, parentOk := t.renderPathLocked(node.ParentID, render)
 	if !parentOk {
 		// If parent node not found, tree is incomplete (parent folder not synced or loaded)
 		// Return false, let upper layer ResolvePathWithFallback fetch it
 		return "", false
 	}
 
-	return filepath.Join(parentPath, node.Name), true
+	return filepath.Join(parentPath, render.Render(node.Name)), true
 }
 
 // GetDescendants gets all descendant nodes
@@ -333,6 +339,28 @@ func (t *FileTree) GetDescendants(rootID string) []model.DescendantInfo {
 	return results
 }
 
+// ListFiles returns path information of every resolvable file (directories excluded)
+func (t *FileTree) ListFiles() []model.DescendantInfo {
+	t.RLock()
+	defer t.RUnlock()
+
+	results := make([]model.DescendantInfo, 0, len(t.nodes))
+	for id, node := range t.nodes {
+		if node.IsDir {
+			continue
+		}
+		if p, ok := t.getPathLocked(id); ok {
+			results = append(results, model.DescendantInfo{
+				ID:      id,
+				Path:    p,
+				IsDir:   false,
+				DriveID: node.DriveID,
+			})
+		}
+	}
+	return results
+}
+
 // ResolvePathWithFallback attempts to get path, falls back to API if not found
 func (t *FileTree) ResolvePathWithFallback(id string) string {
 	p, ok := t.GetPath(id)
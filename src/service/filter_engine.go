@@ -0,0 +1,248 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// filterEvents drops the events rejected by the global filter or by the filter of a sink. The sink
+// filter and its compiled patterns come from the caller's config snapshot so they always agree.
+// The global filter already ran on top-level events; it is applied again for expanded descendants.
+func filterEvents(cm *config.Manager, events []model.ChangeEvent, sink string, sinkFilter model.FilterConfig, sinkPatterns []config.FilterPattern) []model.ChangeEvent {
+	cm.Lock.RLock()
+	globalFilter := cm.Cfg.Filters
+	globalPatterns := cm.FilterPatterns[config.FilterScopeGlobal]
+	logLevel := cm.Cfg.Advanced.LogLevel
+	cm.Lock.RUnlock()
+
+	if len(globalFilter.Rules) == 0 && globalFilter.Default != model.FilterExclude &&
+		len(sinkFilter.Rules) == 0 && sinkFilter.Default != model.FilterExclude {
+		return events
+	}
+
+	out := make([]model.ChangeEvent, 0, len(events))
+	for _, ev := range events {
+		d := evaluateFilter(ev, globalFilter, globalPatterns)
+		if d.Accepted {
+			d = evaluateFilter(ev, sinkFilter, sinkPatterns)
+		}
+		if !d.Accepted {
+			logger.Debug(logLevel, "🚫 [Filter-%s] %s: %s", sink, ev.Path, d.Reason)
+			continue
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// applyGlobalFilter drops the changes rejected by the global filter before caches and sinks see them
+func (s *SyncService) applyGlobalFilter(events []model.ChangeEvent) []model.ChangeEvent {
+	s.ConfigManager.Lock.RLock()
+	filter := s.ConfigManager.Cfg.Filters
+	patterns := s.ConfigManager.FilterPatterns[config.FilterScopeGlobal]
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	if len(filter.Rules) == 0 && filter.Default != model.FilterExclude {
+		return events
+	}
+
+	out := make([]model.ChangeEvent, 0, len(events))
+	for _, ev := range events {
+		if d := evaluateFilter(ev, filter, patterns); !d.Accepted {
+			logger.Debug(logLevel, "🚫 [Filter] %s: %s", ev.Path, d.Reason)
+			continue
+		}
+		out = append(out, ev)
+	}
+	if excluded := len(events) - len(out); excluded > 0 {
+		logger.Info("🚫 [Filter] %d of %d changes excluded", excluded, len(events))
+	}
+	return out
+}
+
+// evaluateFilter decides on an event; a move is accepted when its new or old path is accepted
+func evaluateFilter(ev model.ChangeEvent, filter model.FilterConfig, patterns []config.FilterPattern) model.FilterDecision {
+	d := decideFilter(ev, ev.Path, filter, patterns)
+	if d.Accepted || ev.OldPath == "" {
+		return d
+	}
+	if old := decideFilter(ev, ev.OldPath, filter, patterns); old.Accepted {
+		old.Reason += " (old path)"
+		return old
+	}
+	return d
+}
+
+// decideFilter returns the decision of the first rule matching the event at path p
+func decideFilter(ev model.ChangeEvent, p string, filter model.FilterConfig, patterns []config.FilterPattern) model.FilterDecision {
+	for i, rule := range filter.Rules {
+		var pattern config.FilterPattern
+		if i < len(patterns) {
+			pattern = patterns[i]
+		}
+		conditions, ok := matchFilterRule(ev, p, rule, pattern)
+		if !ok {
+			continue
+		}
+		accepted := rule.Action != model.FilterExclude
+		return model.FilterDecision{
+			Accepted: accepted,
+			Rule:     i,
+			RuleName: rule.Name,
+			Reason:   fmt.Sprintf("%s by rule %d (%s)", filterVerb(accepted), i, conditions),
+		}
+	}
+
+	accepted := filter.Default != model.FilterExclude
+	return model.FilterDecision{
+		Accepted: accepted,
+		Rule:     -1,
+		Reason:   fmt.Sprintf("%s by default (no rule matched)", filterVerb(accepted)),
+	}
+}
+
+// matchFilterRule reports whether every condition of a rule holds and describes them.
+// Size and mime type conditions can't hold for events without Drive metadata (deletes, expanded descendants),
+// so a rule with such a condition never matches them.
+func matchFilterRule(ev model.ChangeEvent, p string, rule model.FilterRule, pattern config.FilterPattern) (string, bool) {
+	if pattern.Err != nil {
+		return "", false
+	}
+	hasMeta := ev.MimeType != ""
+	var conditions []string
+
+	if rule.Glob != "" {
+		target := p
+		if !strings.Contains(rule.Glob, "/") {
+			target = path.Base(p)
+		}
+		if pattern.Glob == nil || !pattern.Glob.MatchString(target) {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("glob %q", rule.Glob))
+	}
+	if rule.Regex != "" {
+		if pattern.Regex == nil || !pattern.Regex.MatchString(p) {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("regex %q", rule.Regex))
+	}
+	if len(rule.Extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), "."))
+		matched := false
+		for _, e := range rule.Extensions {
+			if strings.ToLower(strings.TrimPrefix(e, ".")) == ext {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("extension %q", ext))
+	}
+	if len(rule.MimeTypes) > 0 {
+		if !hasMeta {
+			return "", false
+		}
+		matched := false
+		for _, m := range rule.MimeTypes {
+			if m == ev.MimeType || (strings.HasSuffix(m, "*") && strings.HasPrefix(ev.MimeType, strings.TrimSuffix(m, "*"))) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("mime type %q", ev.MimeType))
+	}
+	if rule.MinSize > 0 || rule.MaxSize > 0 {
+		if ev.IsDir || !hasMeta {
+			return "", false
+		}
+		if (rule.MinSize > 0 && ev.Size < rule.MinSize) || (rule.MaxSize > 0 && ev.Size > rule.MaxSize) {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("size %d", ev.Size))
+	}
+	if len(rule.Drives) > 0 {
+		matched := ""
+		for _, d := range rule.Drives {
+			if d == ev.DriveID || (ev.DriveName != "" && d == ev.DriveName) || (d == "root" && ev.DriveID == "") {
+				matched = d
+				break
+			}
+		}
+		if matched == "" {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("drive %q", matched))
+	}
+	if rule.IsDir != nil {
+		if *rule.IsDir != ev.IsDir {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("is_dir %v", ev.IsDir))
+	}
+
+	if len(conditions) == 0 {
+		return "matches everything", true
+	}
+	return strings.Join(conditions, ", "), true
+}
+
+// filterVerb names a decision in reasons
+func filterVerb(accepted bool) string {
+	if accepted {
+		return "included"
+	}
+	return "excluded"
+}
+
+// ExplainFilters runs a change through the global filter and every sink filter without processing it
+func ExplainFilters(cm *config.Manager, req model.FilterTestRequest) []model.FilterDecision {
+	ev := model.ChangeEvent{
+		Action:    model.ActionCreate,
+		Path:      req.Path,
+		OldPath:   req.OldPath,
+		IsDir:     req.IsDir,
+		Size:      req.Size,
+		MimeType:  req.MimeType,
+		DriveID:   req.DriveID,
+		DriveName: req.DriveName,
+	}
+	if req.OldPath != "" {
+		ev.Action = model.ActionMove
+	}
+
+	cm.Lock.RLock()
+	defer cm.Lock.RUnlock()
+	cfg := cm.Cfg
+
+	explain := func(scope, key string, filter model.FilterConfig) model.FilterDecision {
+		d := evaluateFilter(ev, filter, cm.FilterPatterns[key])
+		d.Scope = scope
+		return d
+	}
+	decisions := []model.FilterDecision{
+		explain(config.FilterScopeGlobal, config.FilterScopeGlobal, cfg.Filters),
+		explain(config.FilterScopeStrm, config.FilterScopeStrm, cfg.Strm.Filters),
+	}
+	for idx, instance := range cfg.Symedia {
+		decisions = append(decisions, explain(SymediaSinkKey(instance.Name), config.SymediaFilterScope(idx), instance.Filters))
+	}
+	for idx, sink := range cfg.Webhooks {
+		decisions = append(decisions, explain("webhook:"+sink.Name, config.WebhookFilterScope(idx), sink.Filters))
+	}
+	for idx, sink := range cfg.Exec {
+		decisions = append(decisions, explain("exec:"+sink.Name, config.ExecFilterScope(idx), sink.Filters))
+	}
+	return decisions
+}
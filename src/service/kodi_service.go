@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// KodiService handles library scans on Kodi hosts via JSON-RPC
+type KodiService struct {
+	ConfigManager *config.Manager
+
+	mu          sync.Mutex
+	cleanTimers map[string]*time.Timer // Host -> pending VideoLibrary.Clean
+}
+
+// NewKodiService creates a new Kodi service
+func NewKodiService(cm *config.Manager) *KodiService {
+	return &KodiService{
+		ConfigManager: cm,
+		cleanTimers:   make(map[string]*time.Timer),
+	}
+}
+
+// kodiRPCRequest represents a Kodi JSON-RPC 2.0 request
+type kodiRPCRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	ID      int                    `json:"id"`
+}
+
+// kodiRPCResponse represents a Kodi JSON-RPC 2.0 response
+type kodiRPCResponse struct {
+	Result interface{} `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Scan triggers VideoLibrary.Scan for the mapped directory on every matching Kodi host
+func (s *KodiService) Scan(originDir string) {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Kodi
+	regexRulesMap := s.ConfigManager.KodiRegexRules
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for idx, instance := range instances {
+		finalDir, matched, _ := mapPathWithRules(originDir, regexRulesMap[idx])
+		if !matched {
+			continue
+		}
+		logger.Debug(logLevel, "🔍 [Kodi-%s] Regex matched: %s -> %s", instance.Name, originDir, finalDir)
+
+		// Kodi compares scan directories against its sources, which always end with a separator
+		if !strings.HasSuffix(finalDir, "/") {
+			finalDir += "/"
+		}
+
+		wg.Add(1)
+		go func(inst model.KodiInstance, dir string) {
+			defer wg.Done()
+			logger.Info("🎬 [Kodi-%s] Scanning: %s", inst.Name, dir)
+			if err := s.call(inst, "VideoLibrary.Scan", map[string]interface{}{"directory": dir, "showdialogs": false}, logLevel); err != nil {
+				logger.Error("❌ [Kodi-%s] Scan failed: %v", inst.Name, err)
+				return
+			}
+			logger.Info("✅ [Kodi-%s] Scan requested", inst.Name)
+		}(instance, finalDir)
+	}
+	wg.Wait()
+}
+
+// ScheduleClean schedules a debounced VideoLibrary.Clean on every Kodi host matching originPath
+func (s *KodiService) ScheduleClean(originPath string) {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Kodi
+	regexRulesMap := s.ConfigManager.KodiRegexRules
+	s.ConfigManager.Lock.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for idx, instance := range instances {
+		if _, matched, _ := mapPathWithRules(originPath, regexRulesMap[idx]); !matched {
+			continue
+		}
+
+		debounce := time.Duration(instance.CleanDebounceSeconds) * time.Second
+		if debounce <= 0 {
+			debounce = 60 * time.Second
+		}
+
+		// Restart the timer so a burst of deletes results in a single clean
+		if t, ok := s.cleanTimers[instance.Host]; ok {
+			t.Stop()
+		}
+		host := instance.Host
+		s.cleanTimers[host] = time.AfterFunc(debounce, func() {
+			s.mu.Lock()
+			delete(s.cleanTimers, host)
+			s.mu.Unlock()
+			s.clean(host)
+		})
+		logger.Verbose(model.LogLevelInfo, "🧹 [Kodi-%s] Library clean scheduled in %v", instance.Name, debounce)
+	}
+}
+
+// clean runs VideoLibrary.Clean on the Kodi host (looked up again to pick up config changes)
+func (s *KodiService) clean(host string) {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Kodi
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	for _, inst := range instances {
+		if inst.Host != host {
+			continue
+		}
+		logger.Info("🧹 [Kodi-%s] Cleaning library...", inst.Name)
+		if err := s.call(inst, "VideoLibrary.Clean", map[string]interface{}{"showdialogs": false}, logLevel); err != nil {
+			logger.Error("❌ [Kodi-%s] Clean failed: %v", inst.Name, err)
+			return
+		}
+		logger.Info("✅ [Kodi-%s] Clean requested", inst.Name)
+		return
+	}
+}
+
+// call sends a JSON-RPC request to a Kodi host
+func (s *KodiService) call(inst model.KodiInstance, method string, params map[string]interface{}, logLevel int) error {
+	payload := kodiRPCRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1}
+	data, _ := json.Marshal(payload)
+
+	fullURL := strings.TrimRight(inst.Host, "/") + "/jsonrpc"
+	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if inst.Username != "" {
+		req.SetBasicAuth(inst.Username, inst.Password)
+	}
+
+	if logLevel >= model.LogLevelDebug {
+		logger.Debug(logLevel, "   👉 URL: %s", fullURL)
+		logger.Debug(logLevel, "   👉 Body: %s", string(data))
+	}
+
+	timeout := inst.Timeout
+	if timeout <= 0 {
+		timeout = 30
+	}
+	cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	logger.Debug(logLevel, "   👈 Response [%s]: %s", resp.Status, string(respBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	var rpcResp kodiRPCResponse
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		return fmt.Errorf("invalid JSON-RPC response: %v", err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("JSON-RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	return nil
+}
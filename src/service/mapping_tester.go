@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/model"
+)
+
+// TestMapping shows how Symedia and every Rclone instance would map a change and what they would be
+// sent, without any network call. A file ID is resolved through the file tree only.
+func (s *SyncService) TestMapping(req model.MappingTestRequest) (model.MappingTestResponse, error) {
+	ev := model.ChangeEvent{Action: req.Action, Path: req.Path, IsDir: req.IsDir, FileID: req.FileID}
+	if ev.Action == "" {
+		ev.Action = model.ActionCreate
+	}
+	if ev.Action != model.ActionCreate && ev.Action != model.ActionDelete && ev.Action != model.ActionModify {
+		return model.MappingTestResponse{}, fmt.Errorf("action must be create, delete or modify")
+	}
+	if ev.Path == "" {
+		if req.FileID == "" {
+			return model.MappingTestResponse{}, fmt.Errorf("path or file_id is required")
+		}
+		node, ok := s.Tree.GetNode(req.FileID)
+		if !ok {
+			return model.MappingTestResponse{}, fmt.Errorf("file %s is not in the file tree", req.FileID)
+		}
+		p, ok := s.Tree.GetPath(req.FileID)
+		if !ok {
+			return model.MappingTestResponse{}, fmt.Errorf("path of file %s is incomplete in the file tree", req.FileID)
+		}
+		ev.Path, ev.IsDir, ev.DriveID = p, node.IsDir, node.DriveID
+	}
+	// Use the cached drive name; a bare path only tells its root, no need to ask Drive for it
+	if name, ok := s.ConfigManager.DriveNames.Load(ev.DriveID); ok && ev.DriveID != "" {
+		ev.DriveName = name.(string)
+	} else {
+		ev.DriveName = strings.SplitN(strings.TrimPrefix(ev.Path, "/"), "/", 2)[0]
+	}
+
+	return model.MappingTestResponse{
+		Path:    ev.Path,
+		Symedia: s.Symedia.dryRun(ev),
+		Rclone:  s.Rclone.dryRun(ev),
+	}, nil
+}
+
+// dryRun maps an event for every Symedia instance and renders the requests it would send
+func (s *SymediaService) dryRun(ev model.ChangeEvent) []model.MappingTestResult {
+	mappingSet := routeMappingSet(s.ConfigManager, ev)
+	results := []model.MappingTestResult{}
+	for _, target := range s.targets() {
+		instance := target.inst
+		rules, source := target.mapping(mappingSet)
+		targets := applyMappingRules(ev.Path, rules)
+		res := mappingTestResult(instance.Name, source, ev.Path, rules, targets, false)
+		if len(targets) == 0 && !instance.NotifyUnmatched {
+			res.Skipped = "no rule matched and notify_unmatched is off"
+		}
+		for _, call := range symediaCalls(ev) {
+			res.Requests = append(res.Requests, buildRequests(target, ev, call.path, call.action, mappingSet)...)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// dryRun maps an event for every Rclone instance and builds the rc requests it would send
+func (s *RcloneService) dryRun(ev model.ChangeEvent) []model.MappingTestResult {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	regexRulesMap := s.ConfigManager.RcloneRegexRules
+	s.ConfigManager.Lock.RUnlock()
+
+	plan := NewRclonePlanFromEvents([]model.ChangeEvent{ev})
+	results := []model.MappingTestResult{}
+	for idx, instance := range instances {
+		targets, smartRoot := mapPathTargets(ev.Path, regexRulesMap[idx])
+		res := mappingTestResult(instance.Name, "mapping", ev.Path, regexRulesMap[idx], targets, smartRoot)
+
+		forget, refresh, recursive := s.rcloneCalls(instance, mapRclonePlan(plan, instance, regexRulesMap[idx], model.LogLevelQuiet))
+		for _, call := range []*rcloneCall{forget, refresh, recursive} {
+			if call != nil {
+				res.Calls = append(res.Calls, model.RcloneCallPreview{Endpoint: call.endpoint, Async: call == recursive, Payload: call.payload})
+			}
+		}
+		if len(res.Calls) == 0 {
+			res.Skipped = "no rule matched"
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// mappingTestResult describes the targets a path was mapped to; the first target is the mapped path
+func mappingTestResult(instance, source, originPath string, rules []config.MappingMatcher, targets []mappedTarget, smartRoot bool) model.MappingTestResult {
+	res := model.MappingTestResult{Instance: instance, Source: source, Rule: -1, SmartRoot: smartRoot, Path: originPath}
+	for i, target := range targets {
+		if i == 0 {
+			res.Rule, res.Pattern, res.Path = target.Rule, rules[target.Rule].String(), target.Path
+		}
+		res.Targets = append(res.Targets, model.MappingTarget{Rule: target.Rule, Pattern: rules[target.Rule].String(), Path: target.Path})
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+
+	"gd-webhook/src/config"
+)
+
+// mappedTarget is one path a mapping produced and the index of the rule that produced it
+type mappedTarget struct {
+	Path string
+	Rule int
+}
+
+// mapPathWithRules applies the first matching mapping rule to originPath.
+// If no rule matches the path itself, it retries with a trailing slash so that
+// rules written for a directory prefix (e.g. "^/Drive/") also match the root
+// directory itself ("smart root" matching). Returns the mapped path, whether a
+// rule matched and whether the match came from the smart root fallback.
+func mapPathWithRules(originPath string, rules []config.MappingMatcher) (string, bool, bool) {
+	finalPath, rule, smartRoot := matchMappingRule(originPath, rules)
+	return finalPath, rule >= 0, smartRoot
+}
+
+// matchMappingRule is mapPathWithRules returning the index of the matching rule (-1 if none)
+func matchMappingRule(originPath string, rules []config.MappingMatcher) (string, int, bool) {
+	targets, smartRoot := mapPathTargets(originPath, rules)
+	if len(targets) == 0 {
+		return originPath, -1, false
+	}
+	return targets[0].Path, targets[0].Rule, smartRoot
+}
+
+// mapPathTargets maps originPath to every target path of the rules, with the same smart root
+// fallback as mapPathWithRules. Returns no target when no rule matches.
+func mapPathTargets(originPath string, rules []config.MappingMatcher) ([]mappedTarget, bool) {
+	if targets := applyMappingRules(originPath, rules); len(targets) > 0 {
+		return targets, false
+	}
+
+	// Try smart root directory matching
+	tempPath := originPath
+	if !strings.HasSuffix(tempPath, "/") {
+		tempPath += "/"
+	}
+	targets := applyMappingRules(tempPath, rules)
+	for i := range targets {
+		targets[i].Path = strings.TrimRight(targets[i].Path, "/")
+		if targets[i].Path == "" {
+			targets[i].Path = "/"
+		}
+	}
+	return targets, len(targets) > 0
+}
+
+// applyMappingRules applies the rules in order: a matching rule stops the mapping unless it sets
+// continue, so several rules can each add a target path (fan-out). Duplicate targets are dropped.
+func applyMappingRules(originPath string, rules []config.MappingMatcher) []mappedTarget {
+	var targets []mappedTarget
+	seen := make(map[string]bool)
+	for j, rule := range rules {
+		finalPath, ok := rule.Map(originPath)
+		if !ok {
+			continue
+		}
+		if !seen[finalPath] {
+			seen[finalPath] = true
+			targets = append(targets, mappedTarget{Path: finalPath, Rule: j})
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+	return targets
+}
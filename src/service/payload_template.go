@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gd-webhook/src/model"
+)
+
+// legacyPlaceholders maps the original placeholders to template actions
+var legacyPlaceholders = strings.NewReplacer(
+	"{{FILE_PATH}}", "{{.FilePath}}",
+	"{{ACTION}}", "{{.Action}}",
+	"{{IS_DIR}}", "{{.IsDir}}",
+	"{{DRIVE_ID}}", "{{.DriveID}}",
+)
+
+// templateFuncs are the helper functions available in payload templates
+var templateFuncs = template.FuncMap{
+	"urlencode":    url.QueryEscape,
+	"pathescape":   escapePathSegments,
+	"base":         path.Base,
+	"dir":          path.Dir,
+	"ext":          func(p string) string { return strings.ToLower(strings.TrimPrefix(path.Ext(p), ".")) },
+	"lower":        strings.ToLower,
+	"upper":        strings.ToUpper,
+	"trimPrefix":   func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix":   func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":      func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"regexReplace": regexReplace,
+	"default": func(def string, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"unix": func(rfc3339 string) int64 {
+		t, err := time.Parse(time.RFC3339, rfc3339)
+		if err != nil {
+			return 0
+		}
+		return t.Unix()
+	},
+}
+
+// parsedTemplates caches parsed templates by source text
+var parsedTemplates sync.Map
+
+// templateRegexes caches the patterns of regexReplace
+var templateRegexes sync.Map
+
+// regexReplace replaces every match of pattern in s
+func regexReplace(pattern, replacement, s string) (string, error) {
+	re, ok := templateRegexes.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		re, _ = templateRegexes.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).ReplaceAllString(s, replacement), nil
+}
+
+// escapePathSegments URL-escapes each segment of a path, keeping the slashes
+func escapePathSegments(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// newTemplateVars builds the template variables of an event sent for originPath
+func newTemplateVars(ev model.ChangeEvent, originPath, finalPath, action string) model.TemplateVars {
+	driveID := ev.DriveID
+	if driveID == "" || driveID == "root" {
+		driveID = "My Drive"
+	}
+	return model.TemplateVars{
+		Action:       action,
+		FilePath:     finalPath,
+		OriginPath:   originPath,
+		FileName:     path.Base(finalPath),
+		Ext:          strings.ToLower(strings.TrimPrefix(path.Ext(finalPath), ".")),
+		ParentDir:    path.Dir(finalPath),
+		OldPath:      ev.OldPath,
+		IsDir:        ev.IsDir,
+		DriveID:      driveID,
+		DriveName:    ev.DriveName,
+		FileID:       ev.FileID,
+		Size:         ev.Size,
+		MimeType:     ev.MimeType,
+		CreatedTime:  ev.CreatedTime,
+		ModifiedTime: ev.ModifiedTime,
+		EventTime:    ev.EventTime,
+		Timestamp:    time.Now().Unix(),
+	}
+}
+
+// renderTemplate executes a template string; strings without actions are returned unchanged
+func renderTemplate(src string, vars model.TemplateVars) (string, error) {
+	if !strings.Contains(src, "{{") {
+		return src, nil
+	}
+	var tmpl *template.Template
+	if cached, ok := parsedTemplates.Load(src); ok {
+		tmpl = cached.(*template.Template)
+	} else {
+		parsed, err := template.New("payload").Funcs(templateFuncs).Option("missingkey=error").Parse(legacyPlaceholders.Replace(src))
+		if err != nil {
+			return src, err
+		}
+		parsedTemplates.Store(src, parsed)
+		tmpl = parsed
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return src, err
+	}
+	return b.String(), nil
+}
+
+// typedValue returns the typed value of a string that consists of a single bool or number
+// variable, so JSON bodies keep "is_dir": true instead of "true"
+func typedValue(src string, vars model.TemplateVars) (interface{}, bool) {
+	switch strings.ReplaceAll(src, " ", "") {
+	case "{{IS_DIR}}", "{{.IsDir}}":
+		return vars.IsDir, true
+	case "{{.Size}}":
+		return vars.Size, true
+	case "{{.Timestamp}}":
+		return vars.Timestamp, true
+	}
+	return nil, false
+}
+
+// renderValue renders every string of a JSON-like value, collecting template errors
+func renderValue(data interface{}, vars model.TemplateVars, errs *[]string) interface{} {
+	switch v := data.(type) {
+	case string:
+		if typed, ok := typedValue(v, vars); ok {
+			return typed
+		}
+		out, err := renderTemplate(v, vars)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%q: %v", v, err))
+		}
+		return out
+	case map[string]interface{}:
+		newMap := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			newMap[k] = renderValue(val, vars, errs)
+		}
+		return newMap
+	case []interface{}:
+		newSlice := make([]interface{}, len(v))
+		for i, val := range v {
+			newSlice[i] = renderValue(val, vars, errs)
+		}
+		return newSlice
+	default:
+		return v
+	}
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// This file re-implements the filename part of rclone's crypt backend
+// (backend/crypt/cipher.go) so paths reported by Drive can be decrypted
+// without shelling out to rclone. File contents are never touched.
+
+const (
+	cryptNameStandard  = "standard"
+	cryptNameObfuscate = "obfuscate"
+	cryptNameOff       = "off"
+
+	cryptEncodingBase32 = "base32"
+	cryptEncodingBase64 = "base64"
+
+	cryptObfuscQuote = '!'
+	cryptOffSuffix   = ".bin"
+)
+
+// rcloneDefaultSalt is used by rclone when no salt (password2) is configured
+var rcloneDefaultSalt = []byte{0xA8, 0x0D, 0xF4, 0x3A, 0x8F, 0xBD, 0x03, 0x08, 0xA7, 0xCA, 0xB8, 0x3E, 0x58, 0x1F, 0x86, 0xB1}
+
+// rcloneObscureKey is the fixed AES key rclone uses to obscure passwords in its config
+var rcloneObscureKey = []byte{
+	0x9c, 0x93, 0x5b, 0x48, 0x73, 0x0a, 0x55, 0x4d,
+	0x6b, 0xfd, 0x7c, 0x63, 0xc8, 0x86, 0xa9, 0x2b,
+	0xd3, 0x90, 0x19, 0x8e, 0xb8, 0x12, 0x8a, 0xfb,
+	0xf4, 0xde, 0x16, 0x2b, 0x8b, 0x95, 0xf6, 0x38,
+}
+
+// base32hex lowercase without padding, as used by rclone
+var cryptBase32 = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+var errNotEncrypted = errors.New("not an encrypted name")
+
+// rcloneNameCipher decrypts rclone crypt file and directory names
+type rcloneNameCipher struct {
+	mode      string
+	encoding  string
+	nameKey   [32]byte
+	nameTweak [16]byte
+	block     cipher.Block
+}
+
+// revealRclonePassword decodes a password obscured by "rclone obscure"
+func revealRclonePassword(obscured string) (string, error) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(obscured)
+	if err != nil {
+		return "", fmt.Errorf("base64 decode failed when revealing password - is it obscured? %w", err)
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return "", errors.New("input too short when revealing password - is it obscured?")
+	}
+	block, err := aes.NewCipher(rcloneObscureKey)
+	if err != nil {
+		return "", err
+	}
+	iv, buf := ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:]
+	cipher.NewCTR(block, iv).XORKeyStream(buf, buf)
+	return string(buf), nil
+}
+
+// newRcloneNameCipher derives the name keys the same way rclone does (scrypt N=16384, r=8, p=1)
+func newRcloneNameCipher(password, salt, mode, encoding string) (*rcloneNameCipher, error) {
+	c := &rcloneNameCipher{mode: mode, encoding: encoding}
+	if c.mode == "" {
+		c.mode = cryptNameStandard
+	}
+	if c.encoding == "" {
+		c.encoding = cryptEncodingBase32
+	}
+	switch c.mode {
+	case cryptNameStandard, cryptNameObfuscate, cryptNameOff:
+	default:
+		return nil, fmt.Errorf("unknown filename_encryption %q", c.mode)
+	}
+	switch c.encoding {
+	case cryptEncodingBase32, cryptEncodingBase64:
+	default:
+		return nil, fmt.Errorf("unsupported filename_encoding %q", c.encoding)
+	}
+
+	const dataKeySize = 32
+	keySize := dataKeySize + len(c.nameKey) + len(c.nameTweak)
+	saltBytes := rcloneDefaultSalt
+	if salt != "" {
+		saltBytes = []byte(salt)
+	}
+
+	var key []byte
+	if password == "" {
+		key = make([]byte, keySize)
+	} else {
+		var err error
+		key, err = scrypt.Key([]byte(password), saltBytes, 16384, 8, 1, keySize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	copy(c.nameKey[:], key[dataKeySize:])
+	copy(c.nameTweak[:], key[dataKeySize+len(c.nameKey):])
+
+	block, err := aes.NewCipher(c.nameKey[:])
+	if err != nil {
+		return nil, err
+	}
+	c.block = block
+	return c, nil
+}
+
+// DecryptFileName decrypts the last path segment of a file
+func (c *rcloneNameCipher) DecryptFileName(name string) (string, error) {
+	if c.mode == cryptNameOff {
+		if !strings.HasSuffix(name, cryptOffSuffix) {
+			return "", errNotEncrypted
+		}
+		return strings.TrimSuffix(name, cryptOffSuffix), nil
+	}
+	return c.decryptSegment(name)
+}
+
+// DecryptDirName decrypts a directory segment
+func (c *rcloneNameCipher) DecryptDirName(name string) (string, error) {
+	if c.mode == cryptNameOff {
+		return name, nil
+	}
+	return c.decryptSegment(name)
+}
+
+func (c *rcloneNameCipher) decryptSegment(segment string) (string, error) {
+	if c.mode == cryptNameObfuscate {
+		return c.deobfuscateSegment(segment)
+	}
+	return c.decryptStandardSegment(segment)
+}
+
+// decryptStandardSegment reverses base32/base64 + EME + PKCS#7
+func (c *rcloneNameCipher) decryptStandardSegment(segment string) (string, error) {
+	if segment == "" {
+		return "", nil
+	}
+
+	var raw []byte
+	var err error
+	if c.encoding == cryptEncodingBase64 {
+		raw, err = base64.RawURLEncoding.DecodeString(segment)
+	} else {
+		raw, err = cryptBase32.DecodeString(strings.ToUpper(segment))
+	}
+	if err != nil {
+		return "", errNotEncrypted
+	}
+	if len(raw) == 0 || len(raw)%aes.BlockSize != 0 || len(raw) > 16*8*aes.BlockSize {
+		return "", errNotEncrypted
+	}
+
+	padded := emeTransform(c.block, c.nameTweak[:], raw, false)
+	plain, err := pkcs7Unpad(padded)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// deobfuscateSegment reverses rclone's "obfuscate" rotation
+func (c *rcloneNameCipher) deobfuscateSegment(segment string) (string, error) {
+	if segment == "" {
+		return "", nil
+	}
+	pos := strings.Index(segment, ".")
+	if pos == -1 {
+		return "", errNotEncrypted
+	}
+	num := segment[:pos]
+	if num == "!" {
+		// No rotation; original name was not valid unicode
+		return segment[pos+1:], nil
+	}
+	dir, err := strconv.Atoi(num)
+	if err != nil {
+		return "", errNotEncrypted
+	}
+	for _, b := range c.nameKey {
+		dir += int(b)
+	}
+
+	var result bytes.Buffer
+	inQuote := false
+	for _, r := range segment[pos+1:] {
+		switch {
+		case inQuote:
+			result.WriteRune(r)
+			inQuote = false
+		case r == cryptObfuscQuote:
+			inQuote = true
+		case r >= '0' && r <= '9':
+			thisDir := (dir % 9) + 1
+			newRune := int(r) - thisDir
+			for newRune < '0' {
+				newRune += 10
+			}
+			result.WriteRune(rune(newRune))
+		case (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z'):
+			thisDir := dir%25 + 1
+			p := int(r - 'A')
+			if p >= 26 {
+				p -= 6
+			}
+			p -= thisDir
+			for p < 0 {
+				p += 52
+			}
+			if p >= 26 {
+				p += 6
+			}
+			result.WriteRune(rune('A' + p))
+		case r >= 0xA0 && r <= 0xFF:
+			thisDir := (dir % 95) + 1
+			newRune := int(r) - thisDir
+			for newRune < 0xA0 {
+				newRune += 96
+			}
+			result.WriteRune(rune(newRune))
+		case r >= 0x100:
+			thisDir := (dir % 127) + 1
+			base := int(r - r%256)
+			newRune := int(r) - thisDir
+			if newRune < base {
+				newRune += 256
+			}
+			result.WriteRune(rune(newRune))
+		default:
+			result.WriteRune(r)
+		}
+	}
+	if !utf8.Valid(result.Bytes()) {
+		return "", errNotEncrypted
+	}
+	return result.String(), nil
+}
+
+// pkcs7Unpad removes PKCS#7 padding with a 16 byte block size
+func pkcs7Unpad(buf []byte) ([]byte, error) {
+	if len(buf) == 0 || len(buf)%aes.BlockSize != 0 {
+		return nil, errNotEncrypted
+	}
+	n := int(buf[len(buf)-1])
+	if n == 0 || n > aes.BlockSize {
+		return nil, errNotEncrypted
+	}
+	for _, b := range buf[len(buf)-n:] {
+		if int(b) != n {
+			return nil, errNotEncrypted
+		}
+	}
+	return buf[:len(buf)-n], nil
+}
+
+// emeTransform implements the EME wide-block mode (Halevi-Rogaway) used by rclone
+// for name encryption. len(data) must be a multiple of 16 and at most 2048.
+func emeTransform(bc cipher.Block, tweak, data []byte, encrypt bool) []byte {
+	m := len(data) / 16
+	out := make([]byte, len(data))
+
+	aesBlock := func(dst, src []byte) {
+		if encrypt {
+			bc.Encrypt(dst, src)
+		} else {
+			bc.Decrypt(dst, src)
+		}
+	}
+
+	// L table: L_i = 2^(i+1) * AES-enc(K, 0)
+	lTable := make([][]byte, m)
+	li := make([]byte, 16)
+	bc.Encrypt(li, make([]byte, 16))
+	for i := 0; i < m; i++ {
+		emeMultByTwo(li, li)
+		lTable[i] = append([]byte(nil), li...)
+	}
+
+	ppj := make([]byte, 16)
+	for j := 0; j < m; j++ {
+		xorBytes(ppj, data[j*16:(j+1)*16], lTable[j])
+		aesBlock(out[j*16:(j+1)*16], ppj)
+	}
+
+	mp := make([]byte, 16)
+	xorBytes(mp, out[0:16], tweak)
+	for j := 1; j < m; j++ {
+		xorBytes(mp, mp, out[j*16:(j+1)*16])
+	}
+
+	mc := make([]byte, 16)
+	aesBlock(mc, mp)
+
+	mm := make([]byte, 16)
+	xorBytes(mm, mp, mc)
+	for j := 1; j < m; j++ {
+		emeMultByTwo(mm, mm)
+		xorBytes(out[j*16:(j+1)*16], out[j*16:(j+1)*16], mm)
+	}
+
+	ccc1 := make([]byte, 16)
+	xorBytes(ccc1, mc, tweak)
+	for j := 1; j < m; j++ {
+		xorBytes(ccc1, ccc1, out[j*16:(j+1)*16])
+	}
+	copy(out[0:16], ccc1)
+
+	for j := 0; j < m; j++ {
+		aesBlock(out[j*16:(j+1)*16], out[j*16:(j+1)*16])
+		xorBytes(out[j*16:(j+1)*16], out[j*16:(j+1)*16], lTable[j])
+	}
+	return out
+}
+
+// emeMultByTwo multiplies a 128-bit block by 2 in GF(2^128) (little-endian convention of EME)
+func emeMultByTwo(out, in []byte) {
+	tmp := make([]byte, 16)
+	tmp[0] = 2 * in[0]
+	if in[15] >= 128 {
+		tmp[0] ^= 135
+	}
+	for j := 1; j < 16; j++ {
+		tmp[j] = 2 * in[j]
+		if in[j-1] >= 128 {
+			tmp[j]++
+		}
+	}
+	copy(out, tmp)
+}
+
+func xorBytes(out, a, b []byte) {
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+}
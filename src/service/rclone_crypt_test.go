@@ -0,0 +1,62 @@
+package service
+
+import "testing"
+
+// Names encrypted by rclone with an empty password and salt (all-zero keys), from rclone's cipher_test.go
+func TestDecryptStandardName(t *testing.T) {
+	tests := []struct {
+		encoding string
+		in       string
+		want     string
+	}{
+		{cryptEncodingBase32, "p0e52nreeaj0a5ea7s64m4j72s", "1"},
+		{cryptEncodingBase32, "l42g6771hnv3an9cgc8cr2n1ng", "12"},
+		{cryptEncodingBase32, "qgm4avr35m5loi1th53ato71v0", "123"},
+		{cryptEncodingBase32, "8ivr2e9plj3c3esisjpdisikos", "1234"},
+		{cryptEncodingBase32, "eeam3li4rnommi3a762h5n7meg", "123456789012345"},
+		{cryptEncodingBase32, "mijbj0frqf6ms7frcr6bd9h0env53jv96pjaaoirk7forcgpt70g", "1234567890123456"},
+		{cryptEncodingBase64, "yBxRX25ypgUVyj8MSxJnFw", "1"},
+		{cryptEncodingBase64, "qQUDHOGN_jVdLIMQzYrhvA", "12"},
+		{cryptEncodingBase64, "1CxFf2Mti1xIPYlGruDh-A", "123"},
+		{cryptEncodingBase64, "RL-xOTmsxsG7kuTy2XJUxw", "1234"},
+		{cryptEncodingBase64, "8noiTP5WkkbEuijsPhOpxQ", "12345678"},
+		{cryptEncodingBase64, "c5Vh1kTd8WtIajmFEtz2dA", "123456789012345"},
+		{cryptEncodingBase64, "tKa5gfvTzW4d-2bMtqYgdf5Rz-k2ZqViW6HfjbIZ6cE", "1234567890123456"},
+	}
+	for _, tt := range tests {
+		c, err := newRcloneNameCipher("", "", cryptNameStandard, tt.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.DecryptFileName(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s %q: got %q, %v; want %q", tt.encoding, tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// Names obfuscated the way rclone's obfuscateSegment does with all-zero keys
+func TestDeobfuscateName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"49.6", "1"},
+		{"99.23", "12"},
+		{"105.Nkrru, cuxrj!!", "Hello, World!"},
+		{"150.Gjmn (9791) 8757q.nlw", "Film (2024) 1080p.mkv"},
+		{"243.Vty¿", "Café"},
+		{"233.疠応 算0阱", "电影 第1集"},
+		{"!.plain", "plain"},
+	}
+	c, err := newRcloneNameCipher("", "", cryptNameObfuscate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got, err := c.DecryptFileName(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// rcloneHealth is the last known state of an instance
+type rcloneHealth struct {
+	healthy   bool
+	checkedAt time.Time
+	since     time.Time // Time of the last state change
+	lastError string
+	version   string
+}
+
+// RcloneStatus is the health of an instance as reported by /api/status
+type RcloneStatus struct {
+	Name      string `json:"name"`
+	Group     string `json:"group,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Active    bool   `json:"active"` // Receives refreshes (first healthy member of its group)
+	Version   string `json:"version,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	CheckedAt string `json:"checked_at,omitempty"`
+	Since     string `json:"since,omitempty"`
+	Queued    int    `json:"queued"` // Operations waiting for this instance (or its group) to recover
+}
+
+// rcloneQueueKey identifies where operations wait while an instance (or its whole group) is down
+func rcloneQueueKey(inst model.RcloneInstance) string {
+	if inst.Group != "" {
+		return "group:" + inst.Group
+	}
+	return "instance:" + inst.Name
+}
+
+// isHealthy reports the last probe result; instances not probed yet count as healthy
+func (s *RcloneService) isHealthy(name string) bool {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	h, ok := s.health[name]
+	return !ok || h.healthy
+}
+
+// route returns the indexes of the instances that receive operations (every healthy ungrouped
+// instance and the first healthy member of each group) and the queue keys with no healthy instance
+func (s *RcloneService) route(instances []model.RcloneInstance) ([]int, []string) {
+	var active []int
+	var queued []string
+	resolved := make(map[string]bool)
+	for idx, inst := range instances {
+		key := rcloneQueueKey(inst)
+		if resolved[key] {
+			continue
+		}
+		if s.isHealthy(inst.Name) {
+			active = append(active, idx)
+			resolved[key] = true
+		}
+	}
+	for _, inst := range instances {
+		key := rcloneQueueKey(inst)
+		if !resolved[key] {
+			queued = append(queued, key)
+			resolved[key] = true
+		}
+	}
+	return active, queued
+}
+
+// enqueue stores operations until an instance of the queue key recovers
+func (s *RcloneService) enqueue(key string, plan *RclonePlan) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	p, ok := s.pending[key]
+	if !ok {
+		p = NewRclonePlan()
+		s.pending[key] = p
+	}
+	p.Merge(plan)
+	logger.Warning("⏸️ [Rclone] %d operations queued for %s until it is healthy", p.Size(), key)
+}
+
+// markDown records a failed call outside of the regular probes
+func (s *RcloneService) markDown(inst model.RcloneInstance, err error) {
+	s.setHealth(inst.Name, false, "", err.Error())
+}
+
+// setHealth updates the state of an instance and logs transitions
+func (s *RcloneService) setHealth(name string, healthy bool, version, lastError string) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	now := time.Now()
+	h, ok := s.health[name]
+	if !ok {
+		h = &rcloneHealth{healthy: true, since: now}
+		s.health[name] = h
+	}
+	if h.healthy != healthy {
+		h.since = now
+		if healthy {
+			logger.Info("🟢 [Rclone-%s] Instance is back online", name)
+		} else {
+			logger.Error("🔴 [Rclone-%s] Instance is down: %s", name, lastError)
+		}
+	}
+	h.healthy = healthy
+	h.checkedAt = now
+	h.lastError = lastError
+	if version != "" {
+		h.version = version
+	}
+}
+
+// StartHealthLoop probes every instance periodically and replays queued operations on recovery
+func (s *RcloneService) StartHealthLoop() {
+	for {
+		s.ConfigManager.Lock.RLock()
+		interval := time.Duration(s.ConfigManager.Cfg.Advanced.RcloneHealthCheckSeconds) * time.Second
+		s.ConfigManager.Lock.RUnlock()
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+
+		s.probeAll()
+		s.flushPending()
+		time.Sleep(interval)
+	}
+}
+
+// probeAll checks every configured instance with rc/noop and core/version
+func (s *RcloneService) probeAll() {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	s.ConfigManager.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(inst model.RcloneInstance) {
+			defer wg.Done()
+			// Probes run every few seconds, keep their request details out of debug logs
+			version, err := s.test(inst, model.LogLevelInfo)
+			if err != nil {
+				s.setHealth(inst.Name, false, "", err.Error())
+				return
+			}
+			s.setHealth(inst.Name, true, version, "")
+		}(instance)
+	}
+	wg.Wait()
+}
+
+// flushPending replays queued operations on the instances that are healthy again
+func (s *RcloneService) flushPending() {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	regexRulesMap := s.ConfigManager.RcloneRegexRules
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	active, _ := s.route(instances)
+	known := make(map[string]bool)
+	for _, inst := range instances {
+		known[rcloneQueueKey(inst)] = true
+	}
+
+	for _, idx := range active {
+		inst := instances[idx]
+		key := rcloneQueueKey(inst)
+
+		s.healthMu.Lock()
+		plan, ok := s.pending[key]
+		delete(s.pending, key)
+		s.healthMu.Unlock()
+		if !ok {
+			continue
+		}
+
+		logger.Info("▶️ [Rclone-%s] Replaying %d queued operations", inst.Name, plan.Size())
+		s.applyTo(plan, instances, regexRulesMap, []int{idx}, logLevel)
+	}
+
+	// Drop queues of instances and groups removed from the config
+	s.healthMu.Lock()
+	for key := range s.pending {
+		if !known[key] {
+			delete(s.pending, key)
+		}
+	}
+	s.healthMu.Unlock()
+}
+
+// HealthStatus returns the health of every configured instance
+func (s *RcloneService) HealthStatus() []RcloneStatus {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	s.ConfigManager.Lock.RUnlock()
+
+	active, _ := s.route(instances)
+	isActive := make(map[int]bool, len(active))
+	for _, idx := range active {
+		isActive[idx] = true
+	}
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	result := make([]RcloneStatus, 0, len(instances))
+	for idx, inst := range instances {
+		st := RcloneStatus{
+			Name:    inst.Name,
+			Group:   inst.Group,
+			Healthy: true,
+			Active:  isActive[idx],
+		}
+		if h, ok := s.health[inst.Name]; ok {
+			st.Healthy = h.healthy
+			st.Version = h.version
+			st.LastError = h.lastError
+			st.CheckedAt = h.checkedAt.Format(time.RFC3339)
+			st.Since = h.since.Format(time.RFC3339)
+		}
+		if p, ok := s.pending[rcloneQueueKey(inst)]; ok {
+			st.Queued = p.Size()
+		}
+		result = append(result, st)
+	}
+	return result
+}
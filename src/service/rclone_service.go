@@ -2,10 +2,22 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gd-webhook/src/config"
@@ -13,10 +25,31 @@ import (
 	"gd-webhook/src/model"
 )
 
+// Visibility check outcomes
+const (
+	VisibilityVisible = "visible" // Path is shown by the instance's VFS
+	VisibilityGone    = "gone"    // Deleted path is no longer shown by the VFS
+	VisibilityTimeout = "timeout" // Deadline elapsed before the expected state was observed
+	VisibilityError   = "error"   // rc call failed
+)
+
+// rcloneUnixPrefix marks a host as a unix socket address
+const rcloneUnixPrefix = "unix://"
+
+// errRcloneUnreachable wraps transport errors (connection refused, timeouts, TLS failures)
+var errRcloneUnreachable = errors.New("instance unreachable")
+
 // RcloneService handles Rclone integration
 type RcloneService struct {
 	ConfigManager *config.Manager
 	LimitChan     chan struct{}
+
+	mu      sync.Mutex
+	clients map[string]*http.Client // Connection settings -> client (keeps TLS/socket setup and idle connections)
+
+	healthMu sync.Mutex
+	health   map[string]*rcloneHealth // Instance name -> last probe result
+	pending  map[string]*RclonePlan   // Queue key -> operations waiting for a healthy instance
 }
 
 // NewRcloneService creates a new Rclone service
@@ -24,148 +57,800 @@ func NewRcloneService(cm *config.Manager) *RcloneService {
 	return &RcloneService{
 		ConfigManager: cm,
 		LimitChan:     make(chan struct{}, 5),
+		clients:       make(map[string]*http.Client),
+		health:        make(map[string]*rcloneHealth),
+		pending:       make(map[string]*RclonePlan),
+	}
+}
+
+// RcloneJob is an async refresh job started on an instance
+type RcloneJob struct {
+	Instance string // Instance name
+	Host     string
+	JobID    int64 // 0 if the instance did not return a job ID
+	Dir      string
+}
+
+// VisibilityCheck describes the state a path is expected to reach on the rclone side
+type VisibilityCheck struct {
+	Path   string // Source path (mapped per instance)
+	Exists bool   // true for created/moved-to paths, false for deleted/moved-from paths
+}
+
+// VisibilityResult is the outcome of a VisibilityCheck on one instance
+type VisibilityResult struct {
+	Instance   string
+	Path       string // Mapped path on the instance
+	SourcePath string
+	Outcome    string
+	Elapsed    time.Duration
+}
+
+// RclonePlan collects the VFS operations needed for the changes of one sync run
+type RclonePlan struct {
+	Forget  map[string]bool // Removed path -> isDir (vfs/forget)
+	Refresh map[string]bool // Directory -> recursive (vfs/refresh)
+	parents map[string]bool // Parent directories of every change (for non-rclone caches)
+}
+
+// NewRclonePlan creates an empty plan
+func NewRclonePlan() *RclonePlan {
+	return &RclonePlan{
+		Forget:  make(map[string]bool),
+		Refresh: make(map[string]bool),
+		parents: make(map[string]bool),
+	}
+}
+
+// NewRclonePlanFromEvents builds the plan for a batch of events. Deletes and moves of items
+// inside a deleted or moved directory are covered by the directory's own operation.
+func NewRclonePlanFromEvents(events []model.ChangeEvent) *RclonePlan {
+	deletedDirs := make(map[string]bool)
+	movedDirs := make(map[string]bool) // Old paths
+	for _, ev := range events {
+		if !ev.IsDir {
+			continue
+		}
+		switch ev.Action {
+		case model.ActionDelete:
+			deletedDirs[ev.Path] = true
+		case model.ActionMove:
+			movedDirs[ev.OldPath] = true
+		}
+	}
+
+	p := NewRclonePlan()
+	for _, ev := range events {
+		switch ev.Action {
+		case model.ActionDelete:
+			if !hasAncestorIn(deletedDirs, ev.Path) {
+				p.AddDelete(ev.Path, ev.IsDir)
+			}
+		case model.ActionMove:
+			if !hasAncestorIn(movedDirs, ev.OldPath) {
+				p.AddMove(ev.OldPath, ev.Path, ev.IsDir)
+			}
+		default:
+			p.AddCreate(ev.Path, ev.IsDir)
+		}
+	}
+	return p
+}
+
+// hasAncestorIn reports whether a parent directory of p is in the set
+func hasAncestorIn(dirs map[string]bool, p string) bool {
+	for dir := filepath.Dir(p); dir != p; p, dir = dir, filepath.Dir(dir) {
+		if dirs[dir] {
+			return true
+		}
 	}
+	return false
+}
+
+// AddCreate records a new file or directory
+func (p *RclonePlan) AddCreate(path string, isDir bool) {
+	p.addRefresh(filepath.Dir(path), false)
+	if isDir {
+		p.addRefresh(path, true)
+	}
+}
+
+// AddDelete records a deleted file or directory
+func (p *RclonePlan) AddDelete(path string, isDir bool) {
+	p.Forget[path] = isDir
+	p.parents[filepath.Dir(path)] = true
 }
 
-// Refresh triggers Rclone VFS refresh
-func (s *RcloneService) Refresh(originPath string) {
+// AddMove records a moved or renamed file or directory
+func (p *RclonePlan) AddMove(oldPath, newPath string, isDir bool) {
+	p.AddDelete(oldPath, isDir)
+	p.AddCreate(newPath, isDir)
+}
+
+// addRefresh records a directory refresh; recursive wins over non-recursive
+func (p *RclonePlan) addRefresh(dir string, recursive bool) {
+	p.Refresh[dir] = p.Refresh[dir] || recursive
+	if recursive {
+		p.parents[filepath.Dir(dir)] = true
+	} else {
+		p.parents[dir] = true
+	}
+}
+
+// Merge adds the operations of another plan
+func (p *RclonePlan) Merge(other *RclonePlan) {
+	for path, isDir := range other.Forget {
+		p.Forget[path] = isDir
+	}
+	for dir, recursive := range other.Refresh {
+		p.Refresh[dir] = p.Refresh[dir] || recursive
+	}
+	for dir := range other.parents {
+		p.parents[dir] = true
+	}
+}
+
+// Size returns the number of operations in the plan
+func (p *RclonePlan) Size() int {
+	return len(p.Forget) + len(p.Refresh)
+}
+
+// Empty reports whether the plan has no operations
+func (p *RclonePlan) Empty() bool {
+	return len(p.Forget) == 0 && len(p.Refresh) == 0
+}
+
+// Dirs returns the parent directories touched by the plan, sorted
+func (p *RclonePlan) Dirs() []string {
+	dirs := make([]string, 0, len(p.parents))
+	for d := range p.parents {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// rcloneInstanceOps are the plan operations mapped to one instance
+type rcloneInstanceOps struct {
+	forgetFiles []string
+	forgetDirs  []string
+	refresh     []string // Non-recursive
+	recursive   []string
+}
+
+// Refresh triggers a recursive Rclone VFS refresh of one path and returns the async jobs it started
+func (s *RcloneService) Refresh(originPath string) []RcloneJob {
+	plan := NewRclonePlan()
+	plan.Refresh[originPath] = true
+	return s.Apply(plan)
+}
+
+// Apply runs the plan on every instance: vfs/forget for removed paths, non-recursive
+// vfs/refresh for changed directories and an async recursive vfs/refresh for new directories.
+// It returns the async jobs it started.
+func (s *RcloneService) Apply(plan *RclonePlan) []RcloneJob {
 	s.ConfigManager.Lock.RLock()
 	instances := s.ConfigManager.Cfg.Rclone
-	regexRulesMap := s.ConfigManager.RcloneRegexRules // map[int][]*regexp.Regexp
+	regexRulesMap := s.ConfigManager.RcloneRegexRules
 	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
 	s.ConfigManager.Lock.RUnlock()
 
-	if len(instances) == 0 {
-		return
+	if len(instances) == 0 || plan.Empty() {
+		return nil
 	}
 
-	// Refresh all matching instances concurrently
-	for idx, instance := range instances {
-		go func(i int, inst model.RcloneInstance) {
-			regexRules := regexRulesMap[i]
-			finalPath := originPath
-			matched := false
+	active, queued := s.route(instances)
+	for _, key := range queued {
+		s.enqueue(key, plan)
+	}
+	return s.applyTo(plan, instances, regexRulesMap, active, logLevel)
+}
 
-			// Check regex rules
-			for j, rule := range inst.Mapping {
-				if j < len(regexRules) && regexRules[j].MatchString(originPath) {
-					finalPath = regexRules[j].ReplaceAllString(originPath, rule.Replacement)
-					matched = true
-					logger.Debug(logLevel, "🔍 [Rclone-%s] Regex matched: %s -> %s", inst.Name, originPath, finalPath)
-					break
-				}
-			}
+// applyTo runs the plan on the given instances concurrently; instances that turn out
+// to be unreachable are marked down and their operations are queued
+func (s *RcloneService) applyTo(plan *RclonePlan, instances []model.RcloneInstance, regexRulesMap map[int][]config.MappingMatcher, targets []int, logLevel int) []RcloneJob {
+	var mu sync.Mutex
+	var jobs []RcloneJob
+	var wg sync.WaitGroup
 
-			if !matched {
-				// Try smart root directory matching
-				tempPath := originPath
-				if !strings.HasSuffix(tempPath, "/") {
-					tempPath += "/"
-				}
-				for j, rule := range inst.Mapping {
-					if j < len(regexRules) && regexRules[j].MatchString(tempPath) {
-						finalPath = regexRules[j].ReplaceAllString(tempPath, rule.Replacement)
-						finalPath = strings.TrimRight(finalPath, "/")
-						if finalPath == "" {
-							finalPath = "/"
-						}
-						matched = true
-						logger.Debug(logLevel, "🔍 [Rclone-%s] Smart root match: %s -> %s", inst.Name, originPath, finalPath)
-						break
-					}
-				}
-			}
+	for _, idx := range targets {
+		instance := instances[idx]
+		ops := mapRclonePlan(plan, instance, regexRulesMap[idx], logLevel)
+		if len(ops.forgetFiles)+len(ops.forgetDirs)+len(ops.refresh)+len(ops.recursive) == 0 {
+			// If no rule matched, skip this instance
+			// In multi-instance setup, each manages its own paths
+			continue
+		}
 
-			if !matched {
-				// If no rule matched, skip this refresh for this instance
-				// In multi-instance setup, each manages its own paths
+		wg.Add(1)
+		go func(inst model.RcloneInstance, ops rcloneInstanceOps) {
+			defer wg.Done()
+			started, err := s.applyInstance(inst, ops, logLevel)
+			if err != nil {
+				s.markDown(inst, err)
+				s.enqueue(rcloneQueueKey(inst), plan)
 				return
 			}
+			mu.Lock()
+			jobs = append(jobs, started...)
+			mu.Unlock()
+		}(instance, ops)
+	}
+	wg.Wait()
+	return jobs
+}
 
-			rcHost := inst.Host
-			rcEp := inst.Endpoint
-			if rcEp == "" {
-				rcEp = "/vfs/refresh"
+// mapRclonePlan maps the plan paths onto one instance, dropping paths no rule matches
+func mapRclonePlan(plan *RclonePlan, inst model.RcloneInstance, rules []config.MappingMatcher, logLevel int) rcloneInstanceOps {
+	mapPath := func(originPath string) []mappedTarget {
+		targets, smartRoot := mapPathTargets(originPath, rules)
+		for _, target := range targets {
+			if smartRoot {
+				logger.Debug(logLevel, "🔍 [Rclone-%s] Smart root match: %s -> %s", inst.Name, originPath, target.Path)
+			} else {
+				logger.Debug(logLevel, "🔍 [Rclone-%s] Rule matched: %s -> %s", inst.Name, originPath, target.Path)
 			}
+		}
+		return targets
+	}
 
-			// Build URL with _async=true parameter
-			fullURL := strings.TrimRight(rcHost, "/") + "/" + strings.TrimLeft(rcEp, "/")
-			if strings.Contains(fullURL, "?") {
-				fullURL += "&_async=true"
+	var ops rcloneInstanceOps
+	seen := make(map[string]bool)
+	for _, p := range sortedKeys(plan.Forget) {
+		// Forgetting a directory also forgets everything below it
+		if hasAncestorIn(plan.Forget, p) {
+			continue
+		}
+		for _, target := range mapPath(p) {
+			if seen["f:"+target.Path] {
+				continue
+			}
+			seen["f:"+target.Path] = true
+			if plan.Forget[p] {
+				ops.forgetDirs = append(ops.forgetDirs, target.Path)
+			} else {
+				ops.forgetFiles = append(ops.forgetFiles, target.Path)
+			}
+		}
+	}
+	for _, p := range sortedKeys(plan.Refresh) {
+		for _, target := range mapPath(p) {
+			if seen["r:"+target.Path] {
+				continue
+			}
+			seen["r:"+target.Path] = true
+			if plan.Refresh[p] {
+				ops.recursive = append(ops.recursive, target.Path)
 			} else {
-				fullURL += "?_async=true"
+				ops.refresh = append(ops.refresh, target.Path)
 			}
+		}
+	}
 
-			payload := map[string]string{"dir": finalPath, "recursive": "true"}
-			data, _ := json.Marshal(payload)
+	before := len(ops.refresh) + len(ops.recursive)
+	ops.refresh, ops.recursive = collapseRefreshDirs(ops.refresh, ops.recursive, inst.FanInThreshold)
+	if after := len(ops.refresh) + len(ops.recursive); after < before {
+		logger.Debug(logLevel, "🔍 [Rclone-%s] Collapsed %d refresh directories to %d", inst.Name, before, after)
+	}
+	return ops
+}
 
-			logger.Info("🔄 [Rclone-%s] Refreshing: %s", inst.Name, finalPath)
+// collapseRefreshDirs reduces mapped refresh directories to a minimal set:
+// directories below a recursive refresh are dropped, and when fanIn > 1, a parent
+// with at least fanIn refreshed subdirectories is refreshed recursively instead.
+// Promotion goes one level up only and never reaches the root, so a handful of
+// refreshes can't turn into a recursive refresh of the whole mount.
+func collapseRefreshDirs(refresh, recursive []string, fanIn int) ([]string, []string) {
+	rec := make(map[string]bool, len(recursive))
+	for _, d := range recursive {
+		rec[d] = true
+	}
+	flat := make(map[string]bool, len(refresh))
+	for _, d := range refresh {
+		flat[d] = true
+	}
 
-			// Limit concurrent requests
-			s.LimitChan <- struct{}{}
-			defer func() { <-s.LimitChan }()
+	// Promote direct parents whose subdirectories fan in past the threshold
+	if fanIn > 1 {
+		children := make(map[string]int)
+		for d := range rec {
+			children[path.Dir(d)]++
+		}
+		for d := range flat {
+			children[path.Dir(d)]++
+		}
+		for parent, n := range children {
+			if n >= fanIn && parent != "." && parent != path.Dir(parent) {
+				rec[parent] = true
+			}
+		}
+	}
 
-			// Create request
-			req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(data))
-			if err != nil {
-				logger.Error("❌ [Rclone-%s] Failed to create request: %v", inst.Name, err)
-				return
+	// Drop directories covered by a recursive refresh of an ancestor (or themselves)
+	for d := range rec {
+		if parent := path.Dir(d); parent != d && coveredByRecursive(rec, parent) {
+			delete(rec, d)
+		}
+	}
+	for d := range flat {
+		if coveredByRecursive(rec, d) {
+			delete(flat, d)
+		}
+	}
+
+	return sortedKeys(flat), sortedKeys(rec)
+}
+
+// coveredByRecursive reports whether dir or one of its ancestors is refreshed recursively
+func coveredByRecursive(rec map[string]bool, dir string) bool {
+	for {
+		if rec[dir] {
+			return true
+		}
+		parent := path.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// rcloneCall is one rc request of the mapped operations
+type rcloneCall struct {
+	endpoint string
+	payload  map[string]interface{}
+}
+
+// rcloneCalls builds the requests of the mapped operations: vfs/forget, the non-recursive vfs/refresh
+// and the async recursive vfs/refresh (nil when there is nothing to send)
+func (s *RcloneService) rcloneCalls(inst model.RcloneInstance, ops rcloneInstanceOps) (forget, refresh, recursive *rcloneCall) {
+	rcEp := inst.Endpoint
+	if rcEp == "" {
+		rcEp = "/vfs/refresh"
+	}
+	// vfs/forget is a fixed rc command on the host, like the other rc calls
+	forgetEp := "/vfs/forget"
+
+	if len(ops.forgetFiles)+len(ops.forgetDirs) > 0 {
+		payload := s.vfsPayload(inst)
+		addNumberedParams(payload, "file", ops.forgetFiles)
+		addNumberedParams(payload, "dir", ops.forgetDirs)
+		forget = &rcloneCall{endpoint: forgetEp, payload: payload}
+	}
+	if len(ops.refresh) > 0 {
+		payload := s.vfsPayload(inst)
+		addNumberedParams(payload, "dir", ops.refresh)
+		payload["recursive"] = "false"
+		refresh = &rcloneCall{endpoint: rcEp, payload: payload}
+	}
+	if len(ops.recursive) > 0 {
+		payload := s.vfsPayload(inst)
+		addNumberedParams(payload, "dir", ops.recursive)
+		payload["recursive"] = "true"
+		recursive = &rcloneCall{endpoint: rcEp, payload: payload}
+	}
+	return forget, refresh, recursive
+}
+
+// applyInstance sends the mapped operations to one instance; the error is only set when the instance is unreachable
+func (s *RcloneService) applyInstance(inst model.RcloneInstance, ops rcloneInstanceOps, logLevel int) ([]RcloneJob, error) {
+	forget, refresh, recursive := s.rcloneCalls(inst, ops)
+
+	// 1. Forget removed paths so they disappear without re-listing their parents
+	if forget != nil {
+		logger.Info("🧹 [Rclone-%s] Forgetting: %s", inst.Name, strings.Join(append(ops.forgetFiles, ops.forgetDirs...), ", "))
+		if _, err := s.rcCall(inst, forget.endpoint, forget.payload, false, logLevel); errors.Is(err, errRcloneUnreachable) {
+			return nil, err
+		} else if err != nil {
+			logger.Error("❌ [Rclone-%s] Forget failed: %v", inst.Name, err)
+		}
+	}
+
+	// 2. Re-list changed directories only (synchronous, so new directories exist for step 3)
+	if refresh != nil {
+		logger.Info("🔄 [Rclone-%s] Refreshing: %s", inst.Name, strings.Join(ops.refresh, ", "))
+		if _, err := s.rcCall(inst, refresh.endpoint, refresh.payload, false, logLevel); errors.Is(err, errRcloneUnreachable) {
+			return nil, err
+		} else if err != nil {
+			logger.Error("❌ [Rclone-%s] Refresh failed: %v", inst.Name, err)
+		} else {
+			logger.Info("✅ [Rclone-%s] Refresh successful", inst.Name)
+		}
+	}
+
+	// 3. Walk new directories recursively in the background
+	if recursive == nil {
+		return nil, nil
+	}
+	logger.Info("🔄 [Rclone-%s] Refreshing recursively: %s", inst.Name, strings.Join(ops.recursive, ", "))
+	respBody, err := s.rcCall(inst, recursive.endpoint, recursive.payload, true, logLevel)
+	if errors.Is(err, errRcloneUnreachable) {
+		return nil, err
+	} else if err != nil {
+		logger.Error("❌ [Rclone-%s] Refresh failed: %v", inst.Name, err)
+		return nil, nil
+	}
+	logger.Info("✅ [Rclone-%s] Refresh started", inst.Name)
+
+	// Async calls answer {"jobid": N}
+	var jobResp struct {
+		JobID int64 `json:"jobid"`
+	}
+	_ = json.Unmarshal(respBody, &jobResp)
+	return []RcloneJob{{Instance: inst.Name, Host: inst.Host, JobID: jobResp.JobID, Dir: strings.Join(ops.recursive, ", ")}}, nil
+}
+
+// vfsPayload returns a vfs/* payload addressing the instance's remote when fs is set
+func (s *RcloneService) vfsPayload(inst model.RcloneInstance) map[string]interface{} {
+	payload := make(map[string]interface{})
+	if inst.Fs != "" {
+		payload["fs"] = inst.Fs
+	}
+	return payload
+}
+
+// addNumberedParams adds values as key, key2, key3... the way vfs/* commands accept multiple paths
+func addNumberedParams(payload map[string]interface{}, key string, values []string) {
+	for i, v := range values {
+		if i == 0 {
+			payload[key] = v
+		} else {
+			payload[key+strconv.Itoa(i+1)] = v
+		}
+	}
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CooldownFor returns the longest cooldown among the active instances handling any of the
+// paths (cooldown_seconds, or advanced.rclone_wait_seconds when unset); 0 if none handles them
+func (s *RcloneService) CooldownFor(paths ...string) time.Duration {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	regexRulesMap := s.ConfigManager.RcloneRegexRules
+	global := s.ConfigManager.Cfg.Advanced.RcloneWaitSeconds
+	s.ConfigManager.Lock.RUnlock()
+
+	var longest time.Duration
+	active, _ := s.route(instances)
+	for _, idx := range active {
+		inst := instances[idx]
+		wait := inst.CooldownSeconds
+		if wait <= 0 {
+			wait = global
+		}
+		d := time.Duration(wait) * time.Second
+		if d <= longest {
+			continue
+		}
+		for _, p := range paths {
+			if p == "" {
+				continue
 			}
-			req.Header.Set("Content-Type", "application/json")
+			if _, matched, _ := mapPathWithRules(p, regexRulesMap[idx]); matched {
+				longest = d
+				break
+			}
+		}
+	}
+	return longest
+}
 
-			// Print detailed request info in debug mode
-			if logLevel >= model.LogLevelDebug {
-				logger.Debug(logLevel, "   👉 Method: %s", req.Method)
-				logger.Debug(logLevel, "   👉 URL: %s", fullURL)
-				logger.Debug(logLevel, "   👉 Headers:")
-				for key, values := range req.Header {
-					for _, value := range values {
-						logger.Debug(logLevel, "      %s: %s", key, value)
+// WaitForVisibility waits for the refresh jobs to finish and then polls the mount's VFS
+// until every check reaches its expected state on every matching instance, or the
+// configured timeout elapses. It returns one result per (check, instance, mapped path).
+func (s *RcloneService) WaitForVisibility(jobs []RcloneJob, checks []VisibilityCheck) []VisibilityResult {
+	s.ConfigManager.Lock.RLock()
+	instances := s.ConfigManager.Cfg.Rclone
+	regexRulesMap := s.ConfigManager.RcloneRegexRules
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	timeout := time.Duration(s.ConfigManager.Cfg.Advanced.VerifyTimeoutSeconds) * time.Second
+	interval := time.Duration(s.ConfigManager.Cfg.Advanced.VerifyIntervalSeconds) * time.Second
+	s.ConfigManager.Lock.RUnlock()
+
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+
+	// 1. Wait for async refresh jobs
+	jobsDone := make(map[string]bool) // Instance name -> all jobs finished
+	for _, inst := range instances {
+		jobsDone[inst.Name] = true
+	}
+	for _, job := range jobs {
+		if job.JobID == 0 {
+			continue
+		}
+		inst, ok := findRcloneInstance(instances, job.Instance)
+		if !ok {
+			continue
+		}
+		if !s.waitJob(inst, job.JobID, deadline, interval, logLevel) {
+			jobsDone[job.Instance] = false
+		}
+	}
+
+	// 2. Poll the VFS per check and instance
+	var results []VisibilityResult
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	active, _ := s.route(instances)
+	for _, idx := range active {
+		instance := instances[idx]
+		for _, check := range checks {
+			targets, _ := mapPathTargets(check.Path, regexRulesMap[idx])
+			for _, target := range targets {
+				wg.Add(1)
+				go func(inst model.RcloneInstance, c VisibilityCheck, p string) {
+					defer wg.Done()
+					outcome := VisibilityTimeout
+					if jobsDone[inst.Name] {
+						outcome = s.pollVFS(inst, p, c.Exists, deadline, interval, logLevel)
 					}
-				}
-				logger.Debug(logLevel, "   👉 Body: %s", string(data))
+					mu.Lock()
+					results = append(results, VisibilityResult{
+						Instance:   inst.Name,
+						Path:       p,
+						SourcePath: c.Path,
+						Outcome:    outcome,
+						Elapsed:    time.Since(start),
+					})
+					mu.Unlock()
+				}(instance, check, target.Path)
 			}
+		}
+	}
+	wg.Wait()
+	return results
+}
 
-			timeout := inst.Timeout
-			if timeout <= 0 {
-				timeout = 60
+// waitJob polls job/status until the job finishes or the deadline elapses
+func (s *RcloneService) waitJob(inst model.RcloneInstance, jobID int64, deadline time.Time, interval time.Duration, logLevel int) bool {
+	for {
+		respBody, err := s.rcCall(inst, "/job/status", map[string]interface{}{"jobid": jobID}, false, logLevel)
+		if err == nil {
+			var st struct {
+				Finished bool   `json:"finished"`
+				Success  bool   `json:"success"`
+				Error    string `json:"error"`
 			}
-			cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
-			resp, err := cl.Do(req)
-			if err != nil {
-				logger.Error("❌ [Rclone-%s] Refresh failed: %v", inst.Name, err)
-				return
+			if json.Unmarshal(respBody, &st) == nil && st.Finished {
+				if !st.Success {
+					logger.Warning("⚠️ [Rclone-%s] Refresh job %d failed: %s", inst.Name, jobID, st.Error)
+				}
+				return true
 			}
-			defer resp.Body.Close()
-
-			// Read response body for debug
-			respBody, _ := io.ReadAll(resp.Body)
+		} else {
+			logger.Debug(logLevel, "🔍 [Rclone-%s] job/status %d failed: %v", inst.Name, jobID, err)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return false
+		}
+		time.Sleep(interval)
+	}
+}
 
-			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-				logger.Info("✅ [Rclone-%s] Refresh successful [%s]", inst.Name, resp.Status)
-				if logLevel >= model.LogLevelDebug {
-					logger.Debug(logLevel, "   👈 Response Status: %s", resp.Status)
-					logger.Debug(logLevel, "   👈 Response Body: %s", string(respBody))
-				}
-			} else {
-				logger.Error("❌ [Rclone-%s] Refresh error [%s]", inst.Name, resp.Status)
-				if logLevel >= model.LogLevelDebug {
-					logger.Debug(logLevel, "   👈 Response Status: %s", resp.Status)
-					logger.Debug(logLevel, "   👈 Response Body: %s", string(respBody))
-				}
+// pollVFS polls the path through the mount's VFS until it reaches the expected state. vfs/refresh
+// resolves each dir through the VFS directory cache and answers "OK" for a directory, "invalid
+// argument" for a file and "file does not exist" when the mount doesn't show the path.
+func (s *RcloneService) pollVFS(inst model.RcloneInstance, path string, exists bool, deadline time.Time, interval time.Duration, logLevel int) string {
+	payload := s.vfsPayload(inst)
+	payload["dir"] = strings.Trim(path, "/")
+	payload["recursive"] = "false"
+	for {
+		respBody, err := s.rcCall(inst, "/vfs/refresh", payload, false, logLevel)
+		var st struct {
+			Result map[string]string `json:"result"`
+		}
+		if err == nil {
+			err = json.Unmarshal(respBody, &st)
+		}
+		if err != nil {
+			logger.Debug(logLevel, "🔍 [Rclone-%s] VFS lookup failed: %v", inst.Name, err)
+			if time.Now().Add(interval).After(deadline) {
+				return VisibilityError
 			}
-		}(idx, instance)
+			time.Sleep(interval)
+			continue
+		}
+
+		state := ""
+		for _, v := range st.Result {
+			state = v
+		}
+		switch {
+		case exists && (state == "OK" || state == "invalid argument"):
+			return VisibilityVisible
+		case !exists && state == "file does not exist":
+			return VisibilityGone
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return VisibilityTimeout
+		}
+		time.Sleep(interval)
 	}
 }
 
-// WaitForCooldown waits for Rclone cooldown period
-func (s *RcloneService) WaitForCooldown() {
+// Test checks connectivity and credentials of an instance with rc/noop and core/version
+func (s *RcloneService) Test(inst model.RcloneInstance) (string, error) {
 	s.ConfigManager.Lock.RLock()
-	wait := time.Duration(s.ConfigManager.Cfg.Advanced.RcloneWaitSeconds) * time.Second
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
 	s.ConfigManager.Lock.RUnlock()
 
-	if wait > 0 {
-		logger.Verbose(model.LogLevelInfo, "⏳ Rclone cooldown (%v)...", wait)
-		time.Sleep(wait)
+	return s.test(inst, logLevel)
+}
+
+// test runs the rc/noop and core/version calls, logging request details at the given level
+func (s *RcloneService) test(inst model.RcloneInstance, logLevel int) (string, error) {
+	respBody, err := s.rcCall(inst, "/rc/noop", map[string]string{"ping": "gd-watcher"}, false, logLevel)
+	if err != nil {
+		return "", fmt.Errorf("rc/noop: %v", err)
+	}
+	var noop map[string]interface{}
+	if err := json.Unmarshal(respBody, &noop); err != nil || noop["ping"] != "gd-watcher" {
+		return "", fmt.Errorf("rc/noop: unexpected response %s", strings.TrimSpace(string(respBody)))
+	}
+
+	respBody, err = s.rcCall(inst, "/core/version", map[string]string{}, false, logLevel)
+	if err != nil {
+		return "", fmt.Errorf("core/version: %v", err)
+	}
+	var ver struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(respBody, &ver); err != nil {
+		return "", fmt.Errorf("core/version: %v", err)
+	}
+	return ver.Version, nil
+}
+
+// rcCall POSTs a JSON payload to an rc endpoint of the instance and returns the response body
+func (s *RcloneService) rcCall(inst model.RcloneInstance, endpoint string, payload interface{}, async bool, logLevel int) ([]byte, error) {
+	cl, baseURL, err := s.client(inst)
+	if err != nil {
+		return nil, err
+	}
+
+	fullURL := baseURL + "/" + strings.TrimLeft(endpoint, "/")
+	if async {
+		// Build URL with _async=true parameter
+		if strings.Contains(fullURL, "?") {
+			fullURL += "&_async=true"
+		} else {
+			fullURL += "?_async=true"
+		}
+	}
+
+	data, _ := json.Marshal(payload)
+
+	// Limit concurrent requests
+	s.LimitChan <- struct{}{}
+	defer func() { <-s.LimitChan }()
+
+	// Create request
+	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range inst.Headers {
+		req.Header.Set(k, v)
+	}
+	if inst.Username != "" || inst.Password != "" {
+		req.SetBasicAuth(inst.Username, inst.Password)
+	}
+
+	// Print detailed request info in debug mode
+	if logLevel >= model.LogLevelDebug {
+		logger.Debug(logLevel, "   👉 Method: %s", req.Method)
+		logger.Debug(logLevel, "   👉 URL: %s", fullURL)
+		logger.Debug(logLevel, "   👉 Headers:")
+		for key, values := range req.Header {
+			for _, value := range values {
+				if key == "Authorization" {
+					value = "***"
+				}
+				logger.Debug(logLevel, "      %s: %s", key, value)
+			}
+		}
+		logger.Debug(logLevel, "   👉 Body: %s", string(data))
+	}
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRcloneUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body for debug
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if logLevel >= model.LogLevelDebug {
+		logger.Debug(logLevel, "   👈 Response Status: %s", resp.Status)
+		logger.Debug(logLevel, "   👈 Response Body: %s", string(respBody))
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return respBody, fmt.Errorf("HTTP %s (check username/password)", resp.Status)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return respBody, nil
+}
+
+// client returns the cached HTTP client and base URL for the connection settings of an instance
+func (s *RcloneService) client(inst model.RcloneInstance) (*http.Client, string, error) {
+	timeout := inst.Timeout
+	if timeout <= 0 {
+		timeout = 60
+	}
+	key := fmt.Sprintf("%s\x00%s\x00%v\x00%d", inst.Host, inst.CACert, inst.InsecureSkipVerify, timeout)
+
+	baseURL := strings.TrimRight(inst.Host, "/")
+	socket := ""
+	if strings.HasPrefix(inst.Host, rcloneUnixPrefix) {
+		// The host part is irrelevant for unix sockets
+		socket = strings.TrimPrefix(inst.Host, rcloneUnixPrefix)
+		baseURL = "http://rclone"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cl, ok := s.clients[key]; ok {
+		return cl, baseURL, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+	if inst.CACert != "" || inst.InsecureSkipVerify {
+		tlsCfg := &tls.Config{InsecureSkipVerify: inst.InsecureSkipVerify}
+		if inst.CACert != "" {
+			pem, err := os.ReadFile(inst.CACert)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read ca_cert: %v", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, "", fmt.Errorf("no certificates found in ca_cert %s", inst.CACert)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	cl := &http.Client{Transport: transport, Timeout: time.Duration(timeout) * time.Second}
+	s.clients[key] = cl
+	return cl, baseURL, nil
+}
+
+// findRcloneInstance looks up an instance by name
+func findRcloneInstance(instances []model.RcloneInstance, name string) (model.RcloneInstance, bool) {
+	for _, inst := range instances {
+		if inst.Name == name {
+			return inst, true
+		}
 	}
+	return model.RcloneInstance{}, false
 }
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gd-webhook/src/model"
+)
+
+// Routing expressions select events, e.g.
+//
+//	action == "delete" && ext in ["mkv", "mp4"] && drive == "Movies"
+//
+// Fields: action, path, old_path, name, ext, dir, drive, drive_id, mime (strings), size (number), is_dir (bool).
+// Operators: == != < <= > >= in, not in, matches (regex), contains, startswith, endswith, && || ! and parentheses.
+
+// Value types of expression nodes
+const (
+	exprString = "string"
+	exprNumber = "number"
+	exprBool   = "bool"
+	exprList   = "list"
+)
+
+// routeFields are the event fields available in expressions and their types
+var routeFields = map[string]string{
+	"action":   exprString,
+	"path":     exprString,
+	"old_path": exprString,
+	"name":     exprString,
+	"ext":      exprString,
+	"dir":      exprString,
+	"drive":    exprString,
+	"drive_id": exprString,
+	"mime":     exprString,
+	"size":     exprNumber,
+	"is_dir":   exprBool,
+}
+
+// routeExpr is a compiled routing expression
+type routeExpr struct {
+	root exprNode
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	eval(env map[string]interface{}) interface{}
+	typ() string
+}
+
+// configuredExprs caches the compiled expressions of the configured routes. It only holds the current
+// routes, so expressions of tests and former configs are not kept.
+var configuredExprs struct {
+	sync.Mutex
+	whens []string
+	exprs []*routeExpr
+}
+
+// compileRouteExpr parses and type-checks an expression
+func compileRouteExpr(src string) (*routeExpr, error) {
+	p := &exprParser{src: src}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at column %d", tok.text, tok.pos+1)
+	}
+	if root.typ() != exprBool {
+		return nil, fmt.Errorf("expression must be a condition, got a %s", root.typ())
+	}
+	return &routeExpr{root: root}, nil
+}
+
+// compileRoutes compiles the expression of each route; routes that do not compile keep their index with nil
+func compileRoutes(routes []model.RouteRule) []*routeExpr {
+	exprs := make([]*routeExpr, len(routes))
+	for i, route := range routes {
+		exprs[i], _ = compileRouteExpr(route.When)
+	}
+	return exprs
+}
+
+// configuredRouteExprs returns compileRoutes of the configured routes, compiling again only when they change
+func configuredRouteExprs(routes []model.RouteRule) []*routeExpr {
+	configuredExprs.Lock()
+	defer configuredExprs.Unlock()
+
+	same := len(configuredExprs.whens) == len(routes)
+	for i := 0; same && i < len(routes); i++ {
+		same = configuredExprs.whens[i] == routes[i].When
+	}
+	if !same {
+		configuredExprs.whens = make([]string, len(routes))
+		for i, route := range routes {
+			configuredExprs.whens[i] = route.When
+		}
+		configuredExprs.exprs = compileRoutes(routes)
+	}
+	return configuredExprs.exprs
+}
+
+// Match evaluates the expression for an event
+func (e *routeExpr) Match(ev model.ChangeEvent) bool {
+	return e.root.eval(routeEnv(ev)) == true
+}
+
+// routeEnv returns the field values of an event
+func routeEnv(ev model.ChangeEvent) map[string]interface{} {
+	return map[string]interface{}{
+		"action":   ev.Action,
+		"path":     ev.Path,
+		"old_path": ev.OldPath,
+		"name":     path.Base(ev.Path),
+		"ext":      strings.ToLower(strings.TrimPrefix(path.Ext(ev.Path), ".")),
+		"dir":      path.Dir(ev.Path),
+		"drive":    ev.DriveName,
+		"drive_id": ev.DriveID,
+		"mime":     ev.MimeType,
+		"size":     float64(ev.Size),
+		"is_dir":   ev.IsDir,
+	}
+}
+
+// Lexer
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	next   int
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				// Only quotes and backslashes are escaped, so regexes like "\d" keep their backslash
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == c || s[j+1] == '\\') {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string at column %d", i+1)
+			}
+			p.tokens = append(p.tokens, exprToken{tokString, b.String(), i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, exprToken{tokNumber, s[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			p.tokens = append(p.tokens, exprToken{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected character %q at column %d", c, i+1)
+			}
+			p.tokens = append(p.tokens, exprToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, exprToken{tokEOF, "end of expression", len(s)})
+	return nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.next] }
+
+func (p *exprParser) take() exprToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *exprParser) accept(kind int, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// Parser
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "||" {
+		tok := p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != exprBool || right.typ() != exprBool {
+			return nil, fmt.Errorf("operands of || at column %d must be conditions", tok.pos+1)
+		}
+		left = &logicNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "&&" {
+		tok := p.take()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != exprBool || right.typ() != exprBool {
+			return nil, fmt.Errorf("operands of && at column %d must be conditions", tok.pos+1)
+		}
+		left = &logicNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "!" {
+		p.take()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != exprBool {
+			return nil, fmt.Errorf("operand of ! at column %d must be a condition", tok.pos+1)
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	op := tok.text
+	negate := false
+	switch {
+	case tok.kind == tokOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">="):
+	case tok.kind == tokIdent && (op == "in" || op == "matches" || op == "contains" || op == "startswith" || op == "endswith"):
+	case tok.kind == tokIdent && op == "not":
+		p.take()
+		if p.peek().text != "in" {
+			return nil, fmt.Errorf("expected \"in\" after \"not\" at column %d", tok.pos+1)
+		}
+		negate, op = true, "in"
+	default:
+		return left, nil
+	}
+	p.take()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	node := &compareNode{op: op, left: left, right: right, negate: negate}
+	if err := node.check(tok.pos); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokString:
+		return &literalNode{value: tok.text, t: exprString}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at column %d", tok.text, tok.pos+1)
+		}
+		return &literalNode{value: n, t: exprNumber}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true", t: exprBool}, nil
+		}
+		t, ok := routeFields[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at column %d", tok.text, tok.pos+1)
+		}
+		return &fieldNode{name: tok.text, t: t}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(tokOp, ")") {
+				return nil, fmt.Errorf("expected \")\" at column %d", p.peek().pos+1)
+			}
+			return x, nil
+		case "[":
+			list := &listNode{}
+			for !p.accept(tokOp, "]") {
+				if len(list.items) > 0 && !p.accept(tokOp, ",") {
+					return nil, fmt.Errorf("expected \",\" or \"]\" at column %d", p.peek().pos+1)
+				}
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := item.(*literalNode); !ok {
+					return nil, fmt.Errorf("list items must be literals (column %d)", tok.pos+1)
+				}
+				list.items = append(list.items, item)
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at column %d", tok.text, tok.pos+1)
+}
+
+// Nodes
+
+type literalNode struct {
+	value interface{}
+	t     string
+}
+
+func (n *literalNode) eval(map[string]interface{}) interface{} { return n.value }
+func (n *literalNode) typ() string                             { return n.t }
+
+type fieldNode struct {
+	name string
+	t    string
+}
+
+func (n *fieldNode) eval(env map[string]interface{}) interface{} { return env[n.name] }
+func (n *fieldNode) typ() string                                 { return n.t }
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) eval(env map[string]interface{}) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+func (n *listNode) typ() string { return exprList }
+
+type notNode struct {
+	x exprNode
+}
+
+func (n *notNode) eval(env map[string]interface{}) interface{} { return n.x.eval(env) != true }
+func (n *notNode) typ() string                                 { return exprBool }
+
+type logicNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicNode) eval(env map[string]interface{}) interface{} {
+	l := n.left.eval(env) == true
+	if n.op == "&&" {
+		return l && n.right.eval(env) == true
+	}
+	return l || n.right.eval(env) == true
+}
+func (n *logicNode) typ() string { return exprBool }
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+	negate      bool           // not in
+	re          *regexp.Regexp // Compiled right operand of matches
+}
+
+// check validates the operand types of a comparison at parse time
+func (n *compareNode) check(pos int) error {
+	lt, rt := n.left.typ(), n.right.typ()
+	where := fmt.Sprintf("at column %d", pos+1)
+	switch n.op {
+	case "==", "!=":
+		if lt != rt || lt == exprList {
+			return fmt.Errorf("cannot compare %s with %s %s", lt, rt, where)
+		}
+	case "<", "<=", ">", ">=":
+		if lt != rt || (lt != exprNumber && lt != exprString) {
+			return fmt.Errorf("cannot order %s and %s %s", lt, rt, where)
+		}
+	case "in":
+		list, ok := n.right.(*listNode)
+		if !ok {
+			return fmt.Errorf("right side of in must be a list %s", where)
+		}
+		for _, item := range list.items {
+			if item.typ() != lt {
+				return fmt.Errorf("list item of type %s does not match %s %s", item.typ(), lt, where)
+			}
+		}
+	case "matches":
+		lit, ok := n.right.(*literalNode)
+		if lt != exprString || !ok || lit.t != exprString {
+			return fmt.Errorf("matches needs a string on the left and a regex string on the right %s", where)
+		}
+		re, err := regexp.Compile(lit.value.(string))
+		if err != nil {
+			return fmt.Errorf("invalid regex %s: %v", where, err)
+		}
+		n.re = re
+	default: // contains, startswith, endswith
+		if lt != exprString || rt != exprString {
+			return fmt.Errorf("%s needs strings on both sides %s", n.op, where)
+		}
+	}
+	return nil
+}
+
+func (n *compareNode) eval(env map[string]interface{}) interface{} {
+	l := n.left.eval(env)
+	switch n.op {
+	case "matches":
+		return n.re.MatchString(l.(string))
+	case "in":
+		found := false
+		for _, item := range n.right.eval(env).([]interface{}) {
+			if item == l {
+				found = true
+				break
+			}
+		}
+		return found != n.negate
+	}
+
+	r := n.right.eval(env)
+	switch n.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "contains":
+		return strings.Contains(l.(string), r.(string))
+	case "startswith":
+		return strings.HasPrefix(l.(string), r.(string))
+	case "endswith":
+		return strings.HasSuffix(l.(string), r.(string))
+	}
+
+	// Ordering of numbers or strings
+	var cmp int
+	if ln, ok := l.(float64); ok {
+		rn := r.(float64)
+		switch {
+		case ln < rn:
+			cmp = -1
+		case ln > rn:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(l.(string), r.(string))
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+func (n *compareNode) typ() string { return exprBool }
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// stableEntry is a create/modify event waiting for its upload to settle
+type stableEntry struct {
+	ev    model.ChangeEvent
+	sig   string    // Content signature seen by the last check
+	same  int       // Consecutive checks that reported sig
+	since time.Time // Time the event was first held
+}
+
+// holdUnstable removes create/modify events of files from the batch until Drive reports the same
+// size and md5 for advanced.stability_checks checks in a row. Later events of a held file are
+// folded into the held one; released events go through processEvents like any other batch.
+func (s *SyncService) holdUnstable(events []model.ChangeEvent) []model.ChangeEvent {
+	s.ConfigManager.Lock.RLock()
+	checks := s.ConfigManager.Cfg.Advanced.StabilityChecks
+	s.ConfigManager.Lock.RUnlock()
+
+	s.stableMu.Lock()
+	defer s.stableMu.Unlock()
+
+	if checks <= 0 && len(s.stableHeld) == 0 {
+		return events
+	}
+
+	out := make([]model.ChangeEvent, 0, len(events))
+	held := 0
+	for _, ev := range events {
+		e := s.stableHeld[ev.FileID]
+		switch {
+		case e != nil:
+			if keep, ok := s.foldHeldUpload(e, ev); ok {
+				out = append(out, keep)
+			}
+		case checks > 0 && !ev.IsDir && ev.FileID != "" && (ev.Action == model.ActionCreate || ev.Action == model.ActionModify):
+			s.sigMu.Lock()
+			sig := s.signatures[ev.FileID]
+			s.sigMu.Unlock()
+			s.stableHeld[ev.FileID] = &stableEntry{ev: ev, sig: sig, since: time.Now()}
+			held++
+		default:
+			out = append(out, ev)
+		}
+	}
+
+	if held > 0 {
+		logger.Info("⏸️ [Stable] Holding %d uploads until their size and checksum settle", held)
+	}
+	if len(s.stableHeld) > 0 && !s.stableRunning {
+		s.stableRunning = true
+		go s.stabilityLoop()
+	}
+	return out
+}
+
+// foldHeldUpload merges a new event of a held file into it and returns the event still to be
+// processed now, if any (caller must hold stableMu)
+func (s *SyncService) foldHeldUpload(e *stableEntry, ev model.ChangeEvent) (model.ChangeEvent, bool) {
+	switch ev.Action {
+	case model.ActionDelete:
+		delete(s.stableHeld, ev.FileID)
+		if e.ev.Action == model.ActionCreate {
+			logger.Info("🧹 [Stable] %s was deleted before its upload settled, dropping it", e.ev.Path)
+			return ev, false
+		}
+		return ev, true
+
+	case model.ActionMove:
+		e.ev.Path = ev.Path
+		// Sinks never saw a held create, so it simply appears at the new path
+		return ev, e.ev.Action != model.ActionCreate
+
+	default:
+		action := e.ev.Action
+		e.ev = ev
+		e.ev.Action = action
+		return ev, false
+	}
+}
+
+// stabilityLoop checks the held files until none are left
+func (s *SyncService) stabilityLoop() {
+	for {
+		s.ConfigManager.Lock.RLock()
+		checks := s.ConfigManager.Cfg.Advanced.StabilityChecks
+		interval := time.Duration(s.ConfigManager.Cfg.Advanced.StabilityIntervalSeconds) * time.Second
+		timeout := time.Duration(s.ConfigManager.Cfg.Advanced.StabilityTimeoutSeconds) * time.Second
+		s.ConfigManager.Lock.RUnlock()
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		time.Sleep(interval)
+
+		s.stableMu.Lock()
+		ids := make([]string, 0, len(s.stableHeld))
+		for id := range s.stableHeld {
+			ids = append(ids, id)
+		}
+		s.stableMu.Unlock()
+
+		var released []model.ChangeEvent
+		for _, id := range ids {
+			f, err := s.DriveInfo.GetFileState(id)
+
+			s.stableMu.Lock()
+			e, ok := s.stableHeld[id]
+			switch {
+			case !ok:
+				// Folded away by a later delete in the meantime
+
+			case err != nil && isNotFound(err), err == nil && f.Trashed:
+				delete(s.stableHeld, id)
+				logger.Info("🧹 [Stable] %s is gone before its upload settled, dropping it", e.ev.Path)
+
+			case err != nil:
+				logger.Warning("⚠️ [Stable] Failed to check %s: %v", e.ev.Path, err)
+				if timeout > 0 && time.Since(e.since) >= timeout {
+					delete(s.stableHeld, id)
+					released = append(released, e.ev)
+				}
+
+			default:
+				sig := contentSignature(f)
+				if sig == e.sig {
+					e.same++
+				} else {
+					e.sig = sig
+					e.same = 0
+					s.sigMu.Lock()
+					s.signatures[id] = sig
+					s.sigMu.Unlock()
+				}
+				e.ev.Size = f.Size
+				e.ev.ModifiedTime = f.ModifiedTime
+
+				if e.same >= checks {
+					delete(s.stableHeld, id)
+					released = append(released, e.ev)
+				} else if timeout > 0 && time.Since(e.since) >= timeout {
+					logger.Warning("⚠️ [Stable] %s still changing after %v, notifying anyway", e.ev.Path, timeout)
+					delete(s.stableHeld, id)
+					released = append(released, e.ev)
+				}
+			}
+			s.stableMu.Unlock()
+		}
+
+		if len(released) > 0 {
+			logger.Info("✅ [Stable] %d uploads settled, processing them", len(released))
+			s.processEvents(released)
+		}
+
+		s.stableMu.Lock()
+		if len(s.stableHeld) == 0 {
+			s.stableRunning = false
+			s.stableMu.Unlock()
+			return
+		}
+		s.stableMu.Unlock()
+	}
+}
+
+// isNotFound reports whether a Drive API error is a 404
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// StabilityPending returns the number of uploads waiting to settle
+func (s *SyncService) StabilityPending() int {
+	s.stableMu.Lock()
+	defer s.stableMu.Unlock()
+	return len(s.stableHeld)
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+// defaultStrmExtensions are the media extensions used when none are configured
+var defaultStrmExtensions = []string{"mkv", "mp4", "avi", "ts", "m2ts", "iso", "mov", "wmv", "flv", "webm", "rmvb", "mpg", "mpeg", "m4v"}
+
+// StrmService maintains a local tree of .strm files mirroring media files in the FileTree
+type StrmService struct {
+	ConfigManager *config.Manager
+	Tree          *FileTree
+	Crypt         *CryptService
+
+	mu sync.Mutex // Serializes writes to the output directory
+}
+
+// NewStrmService creates a new STRM service
+func NewStrmService(cm *config.Manager, tree *FileTree, cr *CryptService) *StrmService {
+	return &StrmService{
+		ConfigManager: cm,
+		Tree:          tree,
+		Crypt:         cr,
+	}
+}
+
+// strmSettings is a snapshot of the STRM config with compiled rules
+type strmSettings struct {
+	cfg        model.StrmConfig
+	regexRules []config.MappingMatcher
+	extensions map[string]bool
+}
+
+// Apply updates .strm files incrementally for the events of a sync run
+func (s *StrmService) Apply(events []model.ChangeEvent) {
+	st, ok := s.settings()
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	written, removed := 0, 0
+	for _, ev := range events {
+		switch ev.Action {
+		case model.ActionCreate:
+			if !ev.IsDir && s.write(st, ev.Path, ev.FileID, ev.DriveID) {
+				written++
+			}
+		case model.ActionDelete:
+			removed += s.remove(st, ev.Path, ev.IsDir)
+		case model.ActionMove:
+			removed += s.remove(st, ev.OldPath, ev.IsDir)
+			if !ev.IsDir && s.write(st, ev.Path, ev.FileID, ev.DriveID) {
+				written++
+			}
+		}
+	}
+
+	if written > 0 || removed > 0 {
+		logger.Info("📼 [STRM] %d written, %d removed", written, removed)
+	}
+}
+
+// Regenerate rebuilds the whole .strm tree from the FileTree and removes stale files
+func (s *StrmService) Regenerate() error {
+	st, ok := s.settings()
+	if !ok {
+		return fmt.Errorf("STRM generation is disabled or output_dir is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logger.Info("📼 [STRM] Regenerating all .strm files into %s...", st.cfg.OutputDir)
+
+	keep := make(map[string]bool)
+	written := 0
+	for _, f := range s.Tree.ListFiles() {
+		plain := s.Crypt.DecryptPath(f.Path, f.DriveID, false)
+		target, ok := s.targetPath(st, plain)
+		if !ok {
+			continue
+		}
+		keep[target] = true
+		if s.write(st, plain, f.ID, f.DriveID) {
+			written++
+		}
+	}
+
+	// Remove .strm files that no longer have a source file
+	stale := 0
+	_ = filepath.WalkDir(st.cfg.OutputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".strm") {
+			return nil
+		}
+		if !keep[p] {
+			if os.Remove(p) == nil {
+				stale++
+			}
+		}
+		return nil
+	})
+	removeEmptyDirs(st.cfg.OutputDir)
+
+	logger.Info("✅ [STRM] Regenerated: %d written, %d stale removed", written, stale)
+	return nil
+}
+
+// settings returns the current STRM settings, or false when generation is disabled
+func (s *StrmService) settings() (strmSettings, bool) {
+	s.ConfigManager.Lock.RLock()
+	cfg := s.ConfigManager.Cfg.Strm
+	rules := s.ConfigManager.StrmRegexRules
+	s.ConfigManager.Lock.RUnlock()
+
+	if !cfg.Enabled || cfg.OutputDir == "" {
+		return strmSettings{}, false
+	}
+
+	exts := cfg.Extensions
+	if len(exts) == 0 {
+		exts = defaultStrmExtensions
+	}
+	extSet := make(map[string]bool, len(exts))
+	for _, e := range exts {
+		extSet[strings.ToLower(strings.TrimPrefix(e, "."))] = true
+	}
+
+	return strmSettings{cfg: cfg, regexRules: rules, extensions: extSet}, true
+}
+
+// mappedPath applies the STRM mapping rules (source path is kept when nothing matches)
+func (s *StrmService) mappedPath(st strmSettings, originPath string) string {
+	if len(st.cfg.Mapping) == 0 {
+		return originPath
+	}
+	finalPath, _, _ := mapPathWithRules(originPath, st.regexRules)
+	return finalPath
+}
+
+// localPath resolves a mapped path inside the output directory (false if it escapes it)
+func (s *StrmService) localPath(st strmSettings, mapped string) (string, bool) {
+	root := filepath.Clean(st.cfg.OutputDir)
+	p := filepath.Join(root, filepath.FromSlash(mapped))
+	if p != root && !strings.HasPrefix(p, root+string(filepath.Separator)) {
+		return "", false
+	}
+	return p, true
+}
+
+// targetPath returns the .strm file path for a media source path
+func (s *StrmService) targetPath(st strmSettings, originPath string) (string, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(originPath), "."))
+	if !st.extensions[ext] {
+		return "", false
+	}
+	p, ok := s.localPath(st, s.mappedPath(st, originPath))
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(p, filepath.Ext(p)) + ".strm", true
+}
+
+// write creates or updates the .strm file for a source file, returning true if written
+func (s *StrmService) write(st strmSettings, originPath, fileID, driveID string) bool {
+	target, ok := s.targetPath(st, originPath)
+	if !ok {
+		return false
+	}
+
+	content := renderStrmURL(st.cfg.URLTemplate, s.mappedPath(st, originPath), fileID, driveID)
+	if old, err := os.ReadFile(target); err == nil && string(old) == content {
+		return false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		logger.Error("❌ [STRM] Failed to create directory for %s: %v", target, err)
+		return false
+	}
+	if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+		logger.Error("❌ [STRM] Failed to write %s: %v", target, err)
+		return false
+	}
+	logger.Verbose(model.LogLevelDebug, "📼 [STRM] Wrote %s", target)
+	return true
+}
+
+// remove deletes the .strm file (or .strm directory tree) of a source path, returning the number of files removed
+func (s *StrmService) remove(st strmSettings, originPath string, isDir bool) int {
+	if isDir {
+		dir, ok := s.localPath(st, s.mappedPath(st, originPath))
+		if !ok || dir == filepath.Clean(st.cfg.OutputDir) {
+			return 0
+		}
+		count := 0
+		_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				count++
+			}
+			return nil
+		})
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Error("❌ [STRM] Failed to remove %s: %v", dir, err)
+			return 0
+		}
+		removeEmptyParents(filepath.Dir(dir), st.cfg.OutputDir)
+		return count
+	}
+
+	target, ok := s.targetPath(st, originPath)
+	if !ok {
+		return 0
+	}
+	if err := os.Remove(target); err != nil {
+		return 0
+	}
+	removeEmptyParents(filepath.Dir(target), st.cfg.OutputDir)
+	return 1
+}
+
+// renderStrmURL fills the URL template placeholders
+func renderStrmURL(tmpl, filePath, fileID, driveID string) string {
+	segments := strings.Split(filePath, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	r := strings.NewReplacer(
+		"{{FILE_PATH_URLENCODED}}", strings.Join(segments, "/"),
+		"{{FILE_PATH}}", filePath,
+		"{{FILE_ID}}", fileID,
+		"{{DRIVE_ID}}", driveID,
+		"{{FILE_NAME}}", filepath.Base(filePath),
+	)
+	return r.Replace(tmpl)
+}
+
+// removeEmptyParents removes empty directories from dir up to (excluding) root
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty (or already gone)
+		}
+	}
+}
+
+// removeEmptyDirs removes every empty directory below root
+func removeEmptyDirs(root string) {
+	var dirs []string
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && p != root {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	// Deepest first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
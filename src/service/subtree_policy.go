@@ -0,0 +1,139 @@
+package service
+
+import (
+	"strings"
+	"sync"
+
+	"gd-webhook/src/model"
+)
+
+// subtreeItems returns a lazy list of a directory's descendants, decrypted on first use.
+// encOld/plainOld are the previous directory path for moves (empty for deletes).
+func (s *SyncService) subtreeItems(children []model.DescendantInfo, encPath, plainPath, encOld, plainOld, oldDriveID string) func() []model.SubtreeItem {
+	var once sync.Once
+	var items []model.SubtreeItem
+	return func() []model.SubtreeItem {
+		once.Do(func() {
+			items = make([]model.SubtreeItem, 0, len(children))
+			for _, d := range children {
+				item := model.SubtreeItem{
+					Rel:     strings.TrimPrefix(s.Crypt.DecryptPath(d.Path, d.DriveID, d.IsDir), plainPath),
+					FileID:  d.ID,
+					DriveID: d.DriveID,
+					IsDir:   d.IsDir,
+				}
+				if encOld != "" {
+					relPath := strings.TrimPrefix(d.Path, encPath)
+					oldRel := strings.TrimPrefix(s.Crypt.DecryptPath(encOld+relPath, oldDriveID, d.IsDir), plainOld)
+					if oldRel != item.Rel {
+						item.OldRel = oldRel
+					}
+				}
+				items = append(items, item)
+			}
+		})
+		return items
+	}
+}
+
+// expandSubtree builds the events of the descendants of a moved or deleted directory
+func expandSubtree(ev model.ChangeEvent) []model.ChangeEvent {
+	if ev.Descendants == nil || (ev.Action != model.ActionMove && ev.Action != model.ActionDelete) {
+		return nil
+	}
+	items := ev.Descendants()
+	out := make([]model.ChangeEvent, 0, len(items))
+	for _, item := range items {
+		oldRel := item.OldRel
+		if oldRel == "" {
+			oldRel = item.Rel
+		}
+		child := model.ChangeEvent{
+			Action:    ev.Action,
+			Path:      ev.Path + item.Rel,
+			FileID:    item.FileID,
+			DriveID:   item.DriveID,
+			DriveName: ev.DriveName,
+			IsDir:     item.IsDir,
+			EventTime: ev.EventTime,
+			SyncRunID: ev.SyncRunID,
+		}
+		if ev.Action == model.ActionMove {
+			child.OldPath = ev.OldPath + oldRel
+		} else {
+			child.Path = ev.Path + oldRel
+		}
+		out = append(out, child)
+	}
+	return out
+}
+
+// applySubtreePolicy returns the events a sink with the given subtree policy receives
+func applySubtreePolicy(events []model.ChangeEvent, policy string) []model.ChangeEvent {
+	switch policy {
+	case model.SubtreeTopLevel:
+		return topLevelEvents(events)
+
+	case model.SubtreeFiles:
+		var out []model.ChangeEvent
+		for _, ev := range events {
+			for _, child := range expandSubtree(ev) {
+				if !child.IsDir {
+					out = append(out, child)
+				}
+			}
+			if !ev.IsDir {
+				out = append(out, ev)
+			}
+		}
+		return out
+
+	default:
+		var out []model.ChangeEvent
+		for _, ev := range events {
+			children := expandSubtree(ev)
+			// Children of a deleted directory go first (deepest first), like the directory removal itself
+			if ev.Action == model.ActionDelete {
+				out = append(out, children...)
+				out = append(out, ev)
+			} else {
+				out = append(out, ev)
+				out = append(out, children...)
+			}
+		}
+		return out
+	}
+}
+
+// topLevelEvents drops events below a directory created, moved or deleted in the same batch
+func topLevelEvents(events []model.ChangeEvent) []model.ChangeEvent {
+	added := make(map[string]bool)
+	removed := make(map[string]bool)
+	for _, ev := range events {
+		if !ev.IsDir {
+			continue
+		}
+		switch ev.Action {
+		case model.ActionCreate, model.ActionMove:
+			added[ev.Path] = true
+		case model.ActionDelete:
+			removed[ev.Path] = true
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return events
+	}
+
+	out := make([]model.ChangeEvent, 0, len(events))
+	for _, ev := range events {
+		covered := added
+		if ev.Action == model.ActionDelete {
+			covered = removed
+		}
+		if hasAncestorIn(covered, ev.Path) {
+			continue
+		}
+		out = append(out, ev)
+	}
+	return out
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gd-webhook/src/config"
@@ -28,145 +29,248 @@ func NewSymediaService(cm *config.Manager) *SymediaService {
 	}
 }
 
-// SendWebhook sends a webhook notification
-func (s *SymediaService) SendWebhook(originPath, action string, isDir bool, driveID string) {
-	s.ConfigManager.Lock.RLock()
-	regexRules := s.ConfigManager.SARegexRules
-	cfg := s.ConfigManager.Cfg
-	s.ConfigManager.Lock.RUnlock()
-
-	finalPath := originPath
-	matched := false
-
-	for i, rule := range cfg.Mapping {
-		if regexRules[i].MatchString(originPath) {
-			finalPath = regexRules[i].ReplaceAllString(originPath, rule.Replacement)
-			matched = true
-			logger.Debug(cfg.Advanced.LogLevel, "🔍 [SA] Regex matched: %s -> %s", originPath, finalPath)
-			break
+// symediaCall is one request sent to Symedia for an event
+type symediaCall struct {
+	path   string
+	action string
+}
+
+// symediaCalls returns the requests of an event: Symedia expects moves as a delete of the old path
+// followed by a create of the new path, and has no modify action (a create makes it rescan the file)
+func symediaCalls(ev model.ChangeEvent) []symediaCall {
+	switch ev.Action {
+	case model.ActionMove:
+		return []symediaCall{{ev.OldPath, model.ActionDelete}, {ev.Path, model.ActionCreate}}
+	case model.ActionModify:
+		return []symediaCall{{ev.Path, model.ActionCreate}}
+	default:
+		return []symediaCall{{ev.Path, ev.Action}}
+	}
+}
+
+// Send notifies every Symedia instance about the events routed to it; instances run concurrently,
+// requests of one instance are sent in order
+func (s *SymediaService) Send(events []model.ChangeEvent) {
+	var wg sync.WaitGroup
+	for _, target := range s.targets() {
+		inst := target.inst
+		evs := sinkEvents(s.ConfigManager, events, inst.SubtreePolicy, inst.Filters, target.patterns, SymediaSinkKey(inst.Name))
+		if len(evs) == 0 {
+			continue
 		}
+
+		wg.Add(1)
+		go func(t symediaTarget, evs []model.ChangeEvent) {
+			defer wg.Done()
+			logger.Info("📡 [SA-%s] Sending %d notifications...", t.inst.Name, len(evs))
+			for _, ev := range evs {
+				for _, call := range symediaCalls(ev) {
+					s.sendWebhook(t, ev, call.path, call.action, routeMappingSet(s.ConfigManager, ev))
+				}
+			}
+		}(target, evs)
 	}
-	if !matched {
-		logger.Warning("⚠️ [SA] Regex not matched: %s", originPath)
+	wg.Wait()
+}
+
+// Test sends a create notification for a path to one instance by name, or to all when name is empty
+func (s *SymediaService) Test(name, originPath string) {
+	ev := model.ChangeEvent{Action: model.ActionCreate, Path: originPath}
+	for _, target := range s.targets() {
+		if name == "" || target.inst.Name == name {
+			s.sendWebhook(target, ev, originPath, model.ActionCreate, "")
+		}
 	}
+}
 
-	notify := cfg.Symedia.NotifyUnmatched
-	syHost := cfg.Symedia.Host
-	syEp := cfg.Symedia.Endpoint
-	bodyT := cfg.Symedia.BodyTemplate
-	heads := cfg.Symedia.Headers
+// Preview renders the requests of an event for one instance by name, or for all when name is empty,
+// without sending them
+func (s *SymediaService) Preview(ev model.ChangeEvent, name, mappingSet string) []model.TemplatePreview {
+	previews := []model.TemplatePreview{}
+	for _, target := range s.targets() {
+		if name != "" && target.inst.Name != name {
+			continue
+		}
+		for _, call := range symediaCalls(ev) {
+			previews = append(previews, buildRequests(target, ev, call.path, call.action, mappingSet)...)
+		}
+	}
+	return previews
+}
 
-	if !matched && !notify {
-		logger.Debug(cfg.Advanced.LogLevel, "🚫 [SA] Skipping: %s", originPath)
-		return
+// symediaTarget is an instance with the mapping and filter rules compiled for it, read under one lock
+// so a config update during a dispatch can't pair an instance with the rules of another one
+type symediaTarget struct {
+	inst        model.SymediaInstance
+	rules       []config.MappingMatcher // Instance mapping, or path_mapping when it has none
+	source      string                  // mapping | path_mapping
+	mappingSets map[string][]config.MappingMatcher
+	patterns    []config.FilterPattern // Compiled instance filter rules
+}
+
+// targets snapshots the Symedia instances together with their compiled rules
+func (s *SymediaService) targets() []symediaTarget {
+	s.ConfigManager.Lock.RLock()
+	defer s.ConfigManager.Lock.RUnlock()
+
+	targets := make([]symediaTarget, 0, len(s.ConfigManager.Cfg.Symedia))
+	for idx, inst := range s.ConfigManager.Cfg.Symedia {
+		t := symediaTarget{
+			inst:        inst,
+			rules:       s.ConfigManager.SARegexRules,
+			source:      "path_mapping",
+			mappingSets: s.ConfigManager.MappingSetRules,
+			patterns:    s.ConfigManager.FilterPatterns[config.SymediaFilterScope(idx)],
+		}
+		if len(inst.Mapping) > 0 {
+			t.rules, t.source = s.ConfigManager.SymediaRegexRules[idx], "mapping"
+		}
+		targets = append(targets, t)
 	}
+	return targets
+}
 
-	fullURL := strings.TrimRight(syHost, "/") + "/" + strings.TrimLeft(syEp, "/")
-	u, _ := url.Parse(fullURL)
-	q := u.Query()
+// mapping returns the mapping rules the instance uses and where they come from: a mapping set selected
+// by routes, the instance mapping, or path_mapping when the instance has none
+func (t symediaTarget) mapping(mappingSet string) ([]config.MappingMatcher, string) {
+	if rules, ok := t.mappingSets[mappingSet]; ok && mappingSet != "" {
+		return rules, "mapping_set:" + mappingSet
+	}
+	return t.rules, t.source
+}
 
-	// Format Drive ID for display
-	displayDriveID := driveID
-	if displayDriveID == "" || displayDriveID == "root" {
-		displayDriveID = "My Drive"
+// buildRequests renders one request per target path of originPath; an unmatched path gives a single
+// request for the path itself with Matched unset
+func buildRequests(t symediaTarget, ev model.ChangeEvent, originPath, action, mappingSet string) []model.TemplatePreview {
+	rules, _ := t.mapping(mappingSet)
+	targets := applyMappingRules(originPath, rules)
+	if len(targets) == 0 {
+		targets = []mappedTarget{{Path: originPath, Rule: -1}}
 	}
 
-	replacements := map[string]interface{}{
-		"{{FILE_PATH}}": finalPath,
-		"{{ACTION}}":    action,
-		"{{IS_DIR}}":    isDir,
-		"{{DRIVE_ID}}":  displayDriveID,
+	previews := make([]model.TemplatePreview, 0, len(targets))
+	for _, target := range targets {
+		previews = append(previews, buildRequest(t.inst, ev, originPath, target.Path, action, target.Rule >= 0))
 	}
+	return previews
+}
 
-	// Process template in query parameters
-	for k, v := range bodyT {
-		if str, ok := v.(string); ok && !strings.Contains(str, "{{") {
-			q.Set(k, str)
+// buildRequest renders the URL, query, headers and body templates of an instance for a mapped path
+func buildRequest(inst model.SymediaInstance, ev model.ChangeEvent, originPath, finalPath, action string, matched bool) model.TemplatePreview {
+	vars := newTemplateVars(ev, originPath, finalPath, action)
+	preview := model.TemplatePreview{Instance: inst.Name, Vars: vars, Matched: matched, Headers: make(map[string]string)}
+	render := func(src string) string {
+		out, err := renderTemplate(src, vars)
+		if err != nil {
+			preview.Errors = append(preview.Errors, fmt.Sprintf("%q: %v", src, err))
+		}
+		return out
+	}
+
+	fullURL := strings.TrimRight(render(inst.Host), "/") + "/" + strings.TrimLeft(render(inst.Endpoint), "/")
+	u, err := url.Parse(fullURL)
+	if err != nil {
+		preview.Errors = append(preview.Errors, fmt.Sprintf("url %q: %v", fullURL, err))
+		u = &url.URL{}
+	}
+	q := u.Query()
+	if inst.Query != nil {
+		for k, v := range inst.Query {
+			q.Set(k, render(v))
+		}
+	} else {
+		// Without explicit query templates, static top-level body values are sent as query parameters too
+		for k, v := range inst.BodyTemplate {
+			if str, ok := v.(string); ok && !strings.Contains(str, "{{") {
+				q.Set(k, str)
+			}
 		}
 	}
 	u.RawQuery = q.Encode()
+	preview.URL = u.String()
 
-	finalBody := s.processTemplate(bodyT, replacements)
+	for k, v := range inst.Headers {
+		preview.Headers[k] = render(v)
+	}
+	// If user didn't configure authorization, add default basic auth
+	if _, exists := preview.Headers["authorization"]; !exists {
+		preview.Headers["authorization"] = "basic usernamepassword"
+	}
+	// If user didn't configure user-agent, add default user-agent
+	if _, exists := preview.Headers["user-agent"]; !exists {
+		preview.Headers["user-agent"] = "clouddrive2/0.9.8"
+	}
 
-	ts := strconv.FormatInt(time.Now().Unix(), 10)
-	if m, ok := finalBody.(map[string]interface{}); ok {
+	body := renderValue(inst.BodyTemplate, vars, &preview.Errors)
+	ts := strconv.FormatInt(vars.Timestamp, 10)
+	if m, ok := body.(map[string]interface{}); ok {
 		m["event_time"] = ts
 		m["send_time"] = ts
 	}
+	preview.Body = body
+	return preview
+}
 
-	b, _ := json.Marshal(finalBody)
-
-	logger.Info("📡 Sending notification: %s", finalPath)
-	if cfg.Advanced.LogLevel >= model.LogLevelDebug {
-		logger.Debug(cfg.Advanced.LogLevel, "   👉 URL: %s", u.String())
-		logger.Debug(cfg.Advanced.LogLevel, "   👉 Headers: %v", heads)
-		logger.Debug(cfg.Advanced.LogLevel, "   👉 Body: %s", string(b))
+// sendWebhook sends the notifications of an event for one path to an instance, one per mapped path
+func (s *SymediaService) sendWebhook(t symediaTarget, ev model.ChangeEvent, originPath, action, mappingSet string) {
+	for _, prepared := range buildRequests(t, ev, originPath, action, mappingSet) {
+		s.send(t.inst, originPath, prepared)
 	}
+}
 
-	req, _ := http.NewRequest("POST", u.String(), bytes.NewBuffer(b))
-	req.Header.Set("Content-Type", "application/json")
+// send posts one prepared request to an instance
+func (s *SymediaService) send(inst model.SymediaInstance, originPath string, prepared model.TemplatePreview) {
+	cfg := s.ConfigManager.GetConfig()
+	finalPath := prepared.Vars.FilePath
 
-	// Set user-configured headers
-	for k, v := range heads {
-		req.Header.Set(k, v)
+	if prepared.Matched {
+		logger.Debug(cfg.Advanced.LogLevel, "🔍 [SA-%s] Rule matched: %s -> %s", inst.Name, originPath, finalPath)
+	} else {
+		logger.Warning("⚠️ [SA-%s] Rule not matched: %s", inst.Name, originPath)
+		if !inst.NotifyUnmatched {
+			logger.Debug(cfg.Advanced.LogLevel, "🚫 [SA-%s] Skipping: %s", inst.Name, originPath)
+			return
+		}
+	}
+	if len(prepared.Errors) > 0 {
+		// A failed template renders as its source; don't send literal {{...}} to Symedia
+		for _, e := range prepared.Errors {
+			logger.Error("❌ [SA-%s] Template error: %s", inst.Name, e)
+		}
+		logger.Error("❌ [SA-%s] Skipping %s: template failed to render", inst.Name, finalPath)
+		return
 	}
 
-	// If user didn't configure authorization, add default basic auth
-	if _, exists := heads["authorization"]; !exists {
-		req.Header.Set("authorization", "basic usernamepassword")
+	b, _ := json.Marshal(prepared.Body)
+
+	logger.Info("📡 [SA-%s] Sending notification: %s", inst.Name, finalPath)
+	if cfg.Advanced.LogLevel >= model.LogLevelDebug {
+		logger.Debug(cfg.Advanced.LogLevel, "   👉 URL: %s", prepared.URL)
+		logger.Debug(cfg.Advanced.LogLevel, "   👉 Headers: %v", prepared.Headers)
+		logger.Debug(cfg.Advanced.LogLevel, "   👉 Body: %s", string(b))
 	}
 
-	// If user didn't configure user-agent, add default user-agent
-	if _, exists := heads["user-agent"]; !exists {
-		req.Header.Set("user-agent", "clouddrive2/0.9.8")
+	req, _ := http.NewRequest("POST", prepared.URL, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range prepared.Headers {
+		req.Header.Set(k, v)
 	}
 
-	timeout := cfg.Symedia.Timeout
+	timeout := inst.Timeout
 	if timeout <= 0 {
 		timeout = 60
 	}
 	cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
 	resp, err := cl.Do(req)
 	if err != nil {
-		logger.Error("Webhook failed: %v", err)
+		logger.Error("[SA-%s] Webhook failed: %v", inst.Name, err)
 	} else {
 		defer resp.Body.Close()
 		_, _ = io.Copy(io.Discard, resp.Body)
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			logger.Info("✅ [SA] Push successful [%s]", resp.Status)
+			logger.Info("✅ [SA-%s] Push successful [%s]", inst.Name, resp.Status)
 		} else {
-			logger.Error("❌ [SA] Push error [%s]", resp.Status)
+			logger.Error("❌ [SA-%s] Push error [%s]", inst.Name, resp.Status)
 		}
 	}
 }
-
-// processTemplate recursively processes template replacement
-func (s *SymediaService) processTemplate(data interface{}, replacements map[string]interface{}) interface{} {
-	switch v := data.(type) {
-	case string:
-		for k, val := range replacements {
-			if v == k {
-				return val
-			}
-			if strings.Contains(v, k) {
-				strVal := fmt.Sprintf("%v", val)
-				v = strings.Replace(v, k, strVal, -1)
-			}
-		}
-		return v
-	case map[string]interface{}:
-		newMap := make(map[string]interface{})
-		for k, val := range v {
-			newMap[k] = s.processTemplate(val, replacements)
-		}
-		return newMap
-	case []interface{}:
-		newSlice := make([]interface{}, len(v))
-		for i, val := range v {
-			newSlice[i] = s.processTemplate(val, replacements)
-		}
-		return newSlice
-	default:
-		return v
-	}
-}
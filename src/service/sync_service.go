@@ -8,7 +8,6 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +15,7 @@ import (
 	"gd-webhook/src/logger"
 	"gd-webhook/src/model"
 
+	"github.com/google/uuid"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 )
@@ -26,7 +26,14 @@ type SyncService struct {
 	DriveInfo     *DriveService
 	Tree          *FileTree
 	Rclone        *RcloneService
+	Alist         *AlistService
+	CloudDrive2   *CloudDrive2Service
 	Symedia       *SymediaService
+	Kodi          *KodiService
+	Webhook       *WebhookService
+	Exec          *ExecService
+	Strm          *StrmService
+	Crypt         *CryptService
 	TriggerChan   chan struct{}
 
 	// Task statistics
@@ -38,6 +45,25 @@ type SyncService struct {
 	isProcessing          bool   // Whether processing a task
 
 	buildMu sync.Mutex // Mutex for BuildFileTreeSkeleton
+
+	// Last seen content signature of files (md5, or size + modified time) to detect modifications
+	sigMu      sync.Mutex
+	signatures map[string]string
+	startedAt  time.Time
+
+	// Events buffered for compaction
+	compactMu      sync.Mutex
+	compactPending []model.ChangeEvent
+	compactStats   CompactionStats
+
+	// Delete notifications held during the grace window
+	graceMu   sync.Mutex
+	graceHeld map[string]*heldDeletes // File ID -> batch holding its delete
+
+	// Create/modify events waiting for their upload to settle
+	stableMu      sync.Mutex
+	stableHeld    map[string]*stableEntry // File ID -> held event
+	stableRunning bool
 }
 
 // NewSyncService creates a new sync service
@@ -46,7 +72,14 @@ func NewSyncService(
 	ds *DriveService,
 	tree *FileTree,
 	rc *RcloneService,
+	al *AlistService,
+	cd2 *CloudDrive2Service,
 	sy *SymediaService,
+	kd *KodiService,
+	wh *WebhookService,
+	ex *ExecService,
+	st *StrmService,
+	cr *CryptService,
 ) *SyncService {
 	// Load persisted task stats from config
 	cm.Lock.RLock()
@@ -73,8 +106,19 @@ func NewSyncService(
 		DriveInfo:             ds,
 		Tree:                  tree,
 		Rclone:                rc,
+		Alist:                 al,
+		CloudDrive2:           cd2,
 		Symedia:               sy,
+		Kodi:                  kd,
+		Webhook:               wh,
+		Exec:                  ex,
+		Strm:                  st,
+		Crypt:                 cr,
 		TriggerChan:           make(chan struct{}, 20),
+		signatures:            make(map[string]string),
+		startedAt:             time.Now(),
+		graceHeld:             make(map[string]*heldDeletes),
+		stableHeld:            make(map[string]*stableEntry),
 		todayCompletedTasks:   todayCompleted,
 		historyCompletedTasks: historyCompleted,
 		lastResetDate:         lastResetDate,
@@ -353,7 +397,7 @@ func (s *SyncService) SyncOnce() {
 		call := s.DriveInfo.Srv.Changes.List(pageToken).
 			IncludeItemsFromAllDrives(true).
 			SupportsAllDrives(true).
-			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(name, parents, mimeType, trashed, driveId))").
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, time, file(name, parents, mimeType, trashed, driveId, size, md5Checksum, createdTime, modifiedTime))").
 			PageSize(500)
 
 		r, err := call.Do()
@@ -411,13 +455,8 @@ func (s *SyncService) SyncOnce() {
 		return
 	}
 
-	rcloneDirs := make(map[string]bool)
-	type Notif struct {
-		Path, Action string
-		IsDir        bool
-		DriveID      string
-	}
-	var notifs []Notif
+	runID := uuid.New().String()
+	var events []model.ChangeEvent
 	processedIDs := make(map[string]bool)
 
 	for _, change := range allChanges {
@@ -427,23 +466,43 @@ func (s *SyncService) SyncOnce() {
 		}
 		oldPath, foundOld := s.Tree.GetPath(fileID)
 		isDeleted := change.Removed || (change.File != nil && change.File.Trashed)
+		eventTime := change.Time
+		if eventTime == "" {
+			eventTime = time.Now().Format(time.RFC3339)
+		}
 
 		if isDeleted {
 			if foundOld {
-				descendants := s.Tree.GetDescendants(fileID)
+				var root model.DescendantInfo
+				var children []model.DescendantInfo
+				for _, d := range s.Tree.GetDescendants(fileID) {
+					processedIDs[d.ID] = true
+					s.forgetSignature(d.ID)
+					if d.ID == fileID {
+						root = d
+					} else {
+						children = append(children, d)
+					}
+				}
 				// Sort by path length descending, delete children first
-				sort.Slice(descendants, func(i, j int) bool {
-					return len(descendants[i].Path) > len(descendants[j].Path)
+				sort.Slice(children, func(i, j int) bool {
+					return len(children[i].Path) > len(children[j].Path)
 				})
-
-				for _, d := range descendants {
-					processedIDs[d.ID] = true
-					logger.Info("🗑️ [Delete] %s", d.Path)
-					logger.WriteHistory(s.ConfigManager.Cfg, "DELETE", d.Path)
-					notifs = append(notifs, Notif{d.Path, "delete", d.IsDir, d.DriveID})
-					rcloneDirs[filepath.Dir(d.Path)] = true
+				for _, d := range children {
 					s.Tree.RemoveNode(d.ID)
 				}
+				s.Tree.RemoveNode(fileID)
+
+				delPath := s.Crypt.DecryptPath(root.Path, root.DriveID, root.IsDir)
+				ev := s.newEvent(runID, eventTime, model.ActionDelete, delPath, "", fileID, root.DriveID, root.IsDir, nil)
+				if len(children) > 0 {
+					logger.Info("🗑️ [Delete] %s (%d items inside)", delPath, len(children))
+					ev.Descendants = s.subtreeItems(children, root.Path, delPath, "", "", "")
+				} else {
+					logger.Info("🗑️ [Delete] %s", delPath)
+				}
+				logger.WriteHistory(s.ConfigManager.Cfg, "DELETE", delPath)
+				events = append(events, ev)
 			}
 			continue
 		}
@@ -544,67 +603,258 @@ func (s *SyncService) SyncOnce() {
 
 		isDirBool := f.MimeType == "application/vnd.google-apps.folder"
 
+		oldDriveID := f.DriveId
+		if oldNode, ok := s.Tree.GetNode(fileID); ok {
+			oldDriveID = oldNode.DriveID
+		}
+
 		s.Tree.UpdateNode(fileID, f.Name, pid, isDirBool, f.DriveId)
 		processedIDs[fileID] = true
 		newPath := s.Tree.ResolvePathWithFallback(fileID)
 
+		// Paths below rclone crypt remotes are reported decrypted to history and sinks
+		plainNew := s.Crypt.DecryptPath(newPath, f.DriveId, isDirBool)
+
 		if !foundOld {
-			logger.Info("🆕 [Create] %s", newPath)
-			logger.WriteHistory(s.ConfigManager.Cfg, "CREATE", newPath)
-			rcloneDirs[filepath.Dir(newPath)] = true
-			notifs = append(notifs, Notif{newPath, "create", isDirBool, f.DriveId})
+			logger.Info("🆕 [Create] %s", plainNew)
+			logger.WriteHistory(s.ConfigManager.Cfg, "CREATE", plainNew)
+			s.contentChanged(fileID, f)
+			events = append(events, s.newEvent(runID, eventTime, model.ActionCreate, plainNew, "", fileID, f.DriveId, isDirBool, f))
 		} else if oldPath != newPath {
-			logger.Info("✏️ [Move] %s -> %s", oldPath, newPath)
-			logger.WriteHistory(s.ConfigManager.Cfg, "MOVE", newPath)
-			rcloneDirs[filepath.Dir(oldPath)] = true
-			rcloneDirs[filepath.Dir(newPath)] = true
+			plainOld := s.Crypt.DecryptPath(oldPath, oldDriveID, isDirBool)
+			logger.Info("✏️ [Move] %s -> %s", plainOld, plainNew)
+			logger.WriteHistory(s.ConfigManager.Cfg, "MOVE", plainNew)
 
-			// For moves, send old path delete and new path create
-			notifs = append(notifs, Notif{oldPath, "delete", isDirBool, f.DriveId})
-			notifs = append(notifs, Notif{newPath, "create", isDirBool, f.DriveId})
+			s.contentChanged(fileID, f)
+			ev := s.newEvent(runID, eventTime, model.ActionMove, plainNew, plainOld, fileID, f.DriveId, isDirBool, f)
 
 			if isDirBool {
-				descendants := s.Tree.GetDescendants(fileID)
-				for _, d := range descendants {
+				var children []model.DescendantInfo
+				for _, d := range s.Tree.GetDescendants(fileID) {
 					if d.ID == fileID {
 						continue
 					}
 					processedIDs[d.ID] = true
-					relPath := strings.TrimPrefix(d.Path, newPath)
-					oldChildPath := oldPath + relPath
-					logger.Info("   ↳ [ChildMove] %s -> %s", oldChildPath, d.Path)
-					notifs = append(notifs, Notif{oldChildPath, "delete", d.IsDir, d.DriveID})
-					notifs = append(notifs, Notif{d.Path, "create", d.IsDir, d.DriveID})
+					children = append(children, d)
+				}
+				if len(children) > 0 {
+					logger.Info("   ↳ [Move] %d items inside", len(children))
+					ev.Descendants = s.subtreeItems(children, newPath, plainNew, oldPath, plainOld, oldDriveID)
 				}
 			}
+			events = append(events, ev)
+		} else if !isDirBool && s.contentChanged(fileID, f) {
+			logger.Info("📝 [Modify] %s", plainNew)
+			logger.WriteHistory(s.ConfigManager.Cfg, "MODIFY", plainNew)
+			events = append(events, s.newEvent(runID, eventTime, model.ActionModify, plainNew, "", fileID, f.DriveId, false, f))
 		}
 	}
 
-	if len(rcloneDirs) > 0 {
-		logger.Info("🚀 Refreshing %d Rclone directories...", len(rcloneDirs))
+	if newStartPageToken != "" {
+		s.DriveInfo.SaveTokenStr(newStartPageToken)
+		logger.Debug(s.ConfigManager.Cfg.Advanced.LogLevel, "💾 [Diag] Final save of new PageToken: %s", newStartPageToken)
+	}
+
+	if len(events) > 0 {
+		s.compactEvents(events)
+	}
+}
+
+// processEvents refreshes the caches for the (compacted) events and schedules their notifications
+func (s *SyncService) processEvents(events []model.ChangeEvent) {
+	rclonePlan := NewRclonePlanFromEvents(events)
+
+	var jobs []RcloneJob
+	if !rclonePlan.Empty() {
+		dirs := rclonePlan.Dirs()
+		logger.Info("🚀 Refreshing %d directories (Rclone/Alist/CloudDrive2)...", len(dirs))
 		var wg sync.WaitGroup
-		for dir := range rcloneDirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobs = s.Rclone.Apply(rclonePlan)
+		}()
+		for _, dir := range dirs {
 			wg.Add(1)
 			go func(d string) {
 				defer wg.Done()
-				s.Rclone.Refresh(d)
+				s.Alist.Refresh(d)
+				s.CloudDrive2.Refresh(d)
 			}(dir)
 		}
 		wg.Wait()
-		s.Rclone.WaitForCooldown()
 	}
 
-	if len(notifs) > 0 {
-		logger.Info("📡 Sending %d notifications...", len(notifs))
-		for _, n := range notifs {
-			s.Symedia.SendWebhook(n.Path, n.Action, n.IsDir, n.DriveID)
+	events = s.holdDeletes(events)
+	if len(events) > 0 {
+		s.scheduleDispatch(jobs, events)
+	}
+}
+
+// scheduleDispatch sends the events in the background once rclone has caught up, so the
+// sync loop keeps consuming changes. Without visibility checks, each event waits for the
+// longest cooldown among the rclone instances handling its paths (no wait if none does).
+func (s *SyncService) scheduleDispatch(jobs []RcloneJob, events []model.ChangeEvent) {
+	if s.ConfigManager.GetConfig().Advanced.VerifyVisibility {
+		go func() {
+			s.verifyVisibility(jobs, events)
+			s.dispatchEvents(events)
+		}()
+		return
+	}
+
+	var delays []time.Duration
+	groups := make(map[time.Duration][]model.ChangeEvent)
+	for _, ev := range events {
+		d := s.Rclone.CooldownFor(ev.Path, ev.OldPath)
+		if _, ok := groups[d]; !ok {
+			delays = append(delays, d)
 		}
+		groups[d] = append(groups[d], ev)
 	}
 
-	if newStartPageToken != "" {
-		s.DriveInfo.SaveTokenStr(newStartPageToken)
-		logger.Debug(s.ConfigManager.Cfg.Advanced.LogLevel, "💾 [Diag] Final save of new PageToken: %s", newStartPageToken)
+	for _, d := range delays {
+		go func(wait time.Duration, evs []model.ChangeEvent) {
+			if wait > 0 {
+				logger.Verbose(model.LogLevelInfo, "⏳ Rclone cooldown (%v) for %d notifications...", wait, len(evs))
+				time.Sleep(wait)
+			}
+			s.dispatchEvents(evs)
+		}(d, groups[d])
+	}
+}
+
+// contentSignature identifies the content of a file (md5, or size and modified time)
+func contentSignature(f *drive.File) string {
+	if f.Md5Checksum != "" {
+		return f.Md5Checksum
+	}
+	return fmt.Sprintf("%d:%s", f.Size, f.ModifiedTime)
+}
+
+// contentChanged records the content signature of a file and reports whether it differs from the
+// last one seen. Files not seen since startup count as changed only if modified after startup.
+func (s *SyncService) contentChanged(fileID string, f *drive.File) bool {
+	sig := contentSignature(f)
+
+	s.sigMu.Lock()
+	defer s.sigMu.Unlock()
+	last, known := s.signatures[fileID]
+	s.signatures[fileID] = sig
+	if known {
+		return last != sig
+	}
+	modified, err := time.Parse(time.RFC3339, f.ModifiedTime)
+	return err == nil && modified.After(s.startedAt)
+}
+
+// forgetSignature drops the content signature of a removed file
+func (s *SyncService) forgetSignature(fileID string) {
+	s.sigMu.Lock()
+	delete(s.signatures, fileID)
+	s.sigMu.Unlock()
+}
+
+// newEvent builds a ChangeEvent, taking metadata from the Drive file when available
+func (s *SyncService) newEvent(runID, eventTime, action, path, oldPath, fileID, driveID string, isDir bool, f *drive.File) model.ChangeEvent {
+	ev := model.ChangeEvent{
+		Action:    action,
+		Path:      path,
+		OldPath:   oldPath,
+		FileID:    fileID,
+		DriveID:   driveID,
+		DriveName: s.DriveInfo.GetDriveName(driveID),
+		IsDir:     isDir,
+		EventTime: eventTime,
+		SyncRunID: runID,
+	}
+	if f != nil {
+		ev.MimeType = f.MimeType
+		ev.Size = f.Size
+		ev.CreatedTime = f.CreatedTime
+		ev.ModifiedTime = f.ModifiedTime
+	}
+	return ev
+}
+
+// verifyVisibility blocks until the event paths are visible (or gone) through rclone, or the check times out
+func (s *SyncService) verifyVisibility(jobs []RcloneJob, events []model.ChangeEvent) {
+	seen := make(map[string]bool)
+	var checks []VisibilityCheck
+	addCheck := func(path string, exists bool) {
+		key := fmt.Sprintf("%v:%s", exists, path)
+		if path == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		checks = append(checks, VisibilityCheck{Path: path, Exists: exists})
+	}
+	for _, ev := range events {
+		switch ev.Action {
+		case model.ActionDelete:
+			addCheck(ev.Path, false)
+		case model.ActionMove:
+			addCheck(ev.OldPath, false)
+			addCheck(ev.Path, true)
+		default:
+			addCheck(ev.Path, true)
+		}
+	}
+
+	logger.Info("🔎 Verifying visibility of %d paths through Rclone...", len(checks))
+	start := time.Now()
+	results := s.Rclone.WaitForVisibility(jobs, checks)
+
+	timedOut := 0
+	for _, r := range results {
+		switch r.Outcome {
+		case VisibilityTimeout, VisibilityError:
+			timedOut++
+			logger.Warning("⚠️ [Rclone-%s] Visibility check %s after %v: %s", r.Instance, r.Outcome, r.Elapsed.Round(time.Second), r.Path)
+		default:
+			logger.Debug(s.ConfigManager.GetConfig().Advanced.LogLevel, "🔎 [Rclone-%s] %s after %v: %s", r.Instance, r.Outcome, r.Elapsed.Round(time.Millisecond), r.Path)
+		}
+	}
+	if timedOut > 0 {
+		logger.Warning("⚠️ Visibility not confirmed for %d/%d checks, notifying anyway (%v)", timedOut, len(results), time.Since(start).Round(time.Second))
+	} else {
+		logger.Info("✅ Visibility confirmed for %d checks (%v)", len(results), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// dispatchEvents sends the events of a sync run to all notification sinks
+func (s *SyncService) dispatchEvents(events []model.ChangeEvent) {
+	// Local .strm files first, so media servers notified below can already see them
+	s.ConfigManager.Lock.RLock()
+	cfg := *s.ConfigManager.Cfg
+	strmPatterns := s.ConfigManager.FilterPatterns[config.FilterScopeStrm]
+	s.ConfigManager.Lock.RUnlock()
+	s.Strm.Apply(sinkEvents(s.ConfigManager, events, model.SubtreeFiles, cfg.Strm.Filters, strmPatterns, RouteSinkStrm))
+
+	s.Symedia.Send(events)
+
+	// Kodi: scan parent directories of new items, clean (debounced) after deletes
+	if len(cfg.Kodi) > 0 {
+		// Scans and cleans cover whole directories, descendants are never needed
+		kodiDirs := make(map[string]bool)
+		for _, ev := range routeEvents(s.ConfigManager, applySubtreePolicy(events, model.SubtreeTopLevel), RouteSinkKodi) {
+			switch ev.Action {
+			case model.ActionDelete:
+				s.Kodi.ScheduleClean(ev.Path)
+			case model.ActionMove:
+				s.Kodi.ScheduleClean(ev.OldPath)
+				kodiDirs[filepath.Dir(ev.Path)] = true
+			default:
+				kodiDirs[filepath.Dir(ev.Path)] = true
+			}
+		}
+		for dir := range kodiDirs {
+			s.Kodi.Scan(dir)
+		}
 	}
+
+	s.Webhook.Send(events)
+	s.Exec.Send(events)
 }
 
 // TaskStats holds task statistics
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gd-webhook/src/config"
+	"gd-webhook/src/logger"
+	"gd-webhook/src/model"
+)
+
+const (
+	// WebhookSignatureHeader carries "sha256=<hex HMAC of timestamp + "." + body>"
+	WebhookSignatureHeader = "X-GDW-Signature"
+	// WebhookTimestampHeader carries the Unix timestamp used in the signature
+	WebhookTimestampHeader = "X-GDW-Timestamp"
+	// WebhookDeliveryHeader carries the delivery ID (same as the envelope's delivery_id)
+	WebhookDeliveryHeader = "X-GDW-Delivery"
+)
+
+// WebhookService sends ChangeEvents to generic outgoing webhooks
+type WebhookService struct {
+	ConfigManager *config.Manager
+}
+
+// NewWebhookService creates a new generic webhook service
+func NewWebhookService(cm *config.Manager) *WebhookService {
+	return &WebhookService{
+		ConfigManager: cm,
+	}
+}
+
+// Send delivers events to every configured webhook, individually or in batches
+func (s *WebhookService) Send(events []model.ChangeEvent) {
+	s.ConfigManager.Lock.RLock()
+	sinks := s.ConfigManager.Cfg.Webhooks
+	patterns := s.ConfigManager.FilterPatterns
+	logLevel := s.ConfigManager.Cfg.Advanced.LogLevel
+	s.ConfigManager.Lock.RUnlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	for idx, sink := range sinks {
+		if sink.URL == "" {
+			continue
+		}
+		evs := sinkEvents(s.ConfigManager, events, sink.SubtreePolicy, sink.Filters, patterns[config.WebhookFilterScope(idx)], "webhook:"+sink.Name)
+		if len(evs) == 0 {
+			continue
+		}
+		for _, chunk := range chunkEvents(evs, sink) {
+			if err := s.deliver(sink, chunk, logLevel); err != nil {
+				logger.Error("❌ [Webhook-%s] Delivery failed: %v", sink.Name, err)
+			}
+		}
+	}
+}
+
+// chunkEvents splits events into the request bodies a sink expects
+func chunkEvents(events []model.ChangeEvent, sink model.WebhookSink) [][]model.ChangeEvent {
+	size := 1
+	if sink.Batch {
+		size = sink.BatchSize
+		if size <= 0 {
+			size = len(events)
+		}
+	}
+
+	var chunks [][]model.ChangeEvent
+	for start := 0; start < len(events); start += size {
+		end := start + size
+		if end > len(events) {
+			end = len(events)
+		}
+		chunks = append(chunks, events[start:end])
+	}
+	return chunks
+}
+
+// deliver POSTs one envelope, retrying on network errors and 5xx responses
+func (s *WebhookService) deliver(sink model.WebhookSink, events []model.ChangeEvent, logLevel int) error {
+	envelope := model.EventEnvelope{
+		SchemaVersion: model.EventSchemaVersion,
+		DeliveryID:    uuid.New().String(),
+		SentAt:        time.Now().Format(time.RFC3339),
+		Source:        config.GetAppName(),
+		Events:        events,
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	timeout := sink.Timeout
+	if timeout <= 0 {
+		timeout = 30
+	}
+	cl := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	maxRetries := 3
+	var lastErr error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			sleep := time.Duration(1<<attempt) * time.Second
+			logger.Warning("⚠️ [Webhook-%s] %v. Retrying in %v...", sink.Name, lastErr, sleep)
+			time.Sleep(sleep)
+		}
+
+		req, err := http.NewRequest("POST", sink.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", config.GetAppName()+"/"+config.GetAppVersion())
+		for k, v := range sink.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set(WebhookDeliveryHeader, envelope.DeliveryID)
+		if sink.Secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(WebhookTimestampHeader, ts)
+			req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(sink.Secret, ts, body))
+		}
+
+		if logLevel >= model.LogLevelDebug {
+			logger.Debug(logLevel, "   👉 URL: %s", sink.URL)
+			logger.Debug(logLevel, "   👉 Body: %s", string(body))
+		}
+
+		resp, err := cl.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			logger.Info("✅ [Webhook-%s] Delivered %d event(s) [%s]", sink.Name, len(events), resp.Status)
+			return nil
+		}
+		lastErr = fmt.Errorf("HTTP %s", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// SignWebhookPayload computes the hex HMAC-SHA256 of "timestamp.body" with the sink secret
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -184,6 +184,10 @@ export default {
       noRcloneInstances: 'No Rclone instances configured',
       addRcloneInstance: 'Add Instance',
       symedia: 'Symedia Notifications',
+      symediaName: 'Name',
+      symediaNameHint: 'Used to match this instance when saving and in logs',
+      noSymediaInstances: 'No Symedia instances configured',
+      addSymediaInstance: 'Add Instance',
       symediaHost: 'Host Address',
       symediaEndpoint: 'API Endpoint',
       timeout: 'Timeout',
@@ -182,6 +182,10 @@ export default {
       noRcloneInstances: '未配置 Rclone 实例',
       addRcloneInstance: '添加实例',
       symedia: 'Symedia 通知',
+      symediaName: '名称',
+      symediaNameHint: '保存时按名称匹配实例，也用于日志',
+      noSymediaInstances: '未配置 Symedia 实例',
+      addSymediaInstance: '添加实例',
       symediaHost: '主机地址',
       symediaEndpoint: 'API 端点',
       timeout: '超时时间',
@@ -166,6 +166,10 @@ export default {
       noRcloneInstances: '未設定 Rclone 實例',
       addRcloneInstance: '新增實例',
       symedia: 'Symedia 通知',
+      symediaName: '名稱',
+      symediaNameHint: '儲存時依名稱比對實例，也用於日誌',
+      noSymediaInstances: '未設定 Symedia 實例',
+      addSymediaInstance: '新增實例',
       symediaHost: '主機地址',
       symediaEndpoint: 'API 端點',
       timeout: '逾時時間',
@@ -60,16 +60,21 @@ export interface RcloneConfig {
   path_mappings: MappingRule[]
 }
 
-export interface SymediaConfig {
+export interface SymediaInstance {
+  name: string
   host: string
   endpoint: string
   body_template: string
-  path_mappings: MappingRule[]
   notify_unmatched?: boolean
   headers?: Record<string, string>
   timeout?: number
 }
 
+export interface SymediaConfig {
+  instances: SymediaInstance[]
+  path_mappings: MappingRule[]
+}
+
 export interface Config {
   auth: AuthConfig
   oauth: OAuthConfig
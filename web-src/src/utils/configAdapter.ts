@@ -56,19 +56,23 @@ interface BackendConfig {
       replacement: string
     }>
   }>
-  symedia?: {
-    host: string
-    endpoint: string
-    notify_unmatched?: boolean
-    headers?: Record<string, string>
-    body_template?: any
-  }
+  symedia?: BackendSymediaInstance | BackendSymediaInstance[]
   path_mapping?: Array<{
     regex: string
     replacement: string
   }>
 }
 
+interface BackendSymediaInstance {
+  name?: string
+  host: string
+  endpoint: string
+  notify_unmatched?: boolean
+  headers?: Record<string, string>
+  body_template?: any
+  timeout?: number
+}
+
 /**
  * Convert backend config to frontend config format
  */
@@ -125,19 +129,27 @@ export function adaptBackendConfig(backend: BackendConfig): Config {
         .map(m => ({ regex: m.regex || '', replacement: m.replacement || '' }))
     },
     symedia: {
-      host: backend.symedia?.host ?? '',
-      endpoint: backend.symedia?.endpoint ?? '',
-      body_template: backend.symedia?.body_template
-        ? (typeof backend.symedia.body_template === 'string'
-          ? backend.symedia.body_template
-          : JSON.stringify(backend.symedia.body_template))
-        : '',
+      // Older backends return a single object instead of a list
+      instances: (Array.isArray(backend.symedia)
+        ? backend.symedia
+        : backend.symedia ? [backend.symedia] : []
+      ).map(instance => ({
+        name: instance.name ?? '',
+        host: instance.host ?? '',
+        endpoint: instance.endpoint ?? '',
+        body_template: instance.body_template
+          ? (typeof instance.body_template === 'string'
+            ? instance.body_template
+            : JSON.stringify(instance.body_template))
+          : '',
+        notify_unmatched: instance.notify_unmatched ?? false,
+        headers: instance.headers || {},
+        timeout: instance.timeout
+      })),
       path_mappings: (backend.path_mapping || []).map(m => ({
         regex: m.regex ?? '',
         replacement: m.replacement ?? ''
-      })),
-      notify_unmatched: backend.symedia?.notify_unmatched ?? false,
-      headers: backend.symedia?.headers || {}
+      }))
     }
   }
 }
@@ -194,15 +206,17 @@ export function adaptFrontendConfig(frontend: Config): BackendConfig {
       endpoint: instance.endpoint,
       mapping: frontend.rclone.path_mappings
     })),
-    symedia: {
-      host: frontend.symedia.host,
-      endpoint: frontend.symedia.endpoint,
-      notify_unmatched: frontend.symedia.notify_unmatched,
-      body_template: frontend.symedia.body_template
-        ? JSON.parse(frontend.symedia.body_template)
+    symedia: frontend.symedia.instances.map(instance => ({
+      name: instance.name,
+      host: instance.host,
+      endpoint: instance.endpoint,
+      notify_unmatched: instance.notify_unmatched,
+      body_template: instance.body_template
+        ? JSON.parse(instance.body_template)
         : {},
-      headers: frontend.symedia.headers || {}
-    },
+      headers: instance.headers || {},
+      timeout: instance.timeout
+    })),
     path_mapping: frontend.symedia.path_mappings
   }
 }